@@ -0,0 +1,204 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers set on messages moved to a dead-letter queue.
+const (
+	HeaderDeadLetterID       = "x-dead-letter-id"
+	HeaderFailureReason      = "x-failure-reason"
+	HeaderFailedQueue        = "x-failed-queue"
+	HeaderFailedAt           = "x-failed-at"
+	HeaderDeliveryCount      = "x-delivery-count"
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+)
+
+var (
+	workQueuesMu sync.Mutex
+	workQueues   = map[string]bool{}
+)
+
+// WorkQueues lists the queues declared with DeclareWorkQueue in this process.
+func WorkQueues() []string {
+	workQueuesMu.Lock()
+	defer workQueuesMu.Unlock()
+	queues := make([]string, 0, len(workQueues))
+	for q := range workQueues {
+		queues = append(queues, q)
+	}
+	sort.Strings(queues)
+	return queues
+}
+
+// IsWorkQueue reports whether queue was declared with DeclareWorkQueue in this
+// process, and so has a dead-letter queue.
+func IsWorkQueue(queue string) bool {
+	workQueuesMu.Lock()
+	defer workQueuesMu.Unlock()
+	return workQueues[queue]
+}
+
+func DeadLetterExchange(exchange string) string {
+	return exchange + ".dlx"
+}
+
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+// DeliveryLimit is how many times a message may be delivered before it is
+// dead-lettered, from RABBITMQ_DELIVERY_LIMIT (default 5).
+func DeliveryLimit() int {
+	if n, _ := strconv.Atoi(os.Getenv("RABBITMQ_DELIVERY_LIMIT")); n > 0 {
+		return n
+	}
+	return 5
+}
+
+// DeclareWorkQueue declares a consumer queue together with its dead-letter
+// queue: messages rejected or returned more than DeliveryLimit times end up
+// in <queue>.dlq via the <exchange>.dlx exchange, bound by queue name. The
+// queue itself is declared with the same arguments as always, so existing
+// queues are not rejected; dead-lettering is set by ApplyDeadLetterPolicy.
+func DeclareWorkQueue(ch *amqp.Channel, queue, exchange, routingKey string) (string, error) {
+	dlx := DeadLetterExchange(exchange)
+	if err := EnsureDirectExchange(ch, dlx); err != nil {
+		return "", err
+	}
+	// The dead-letter queue is classic: listing it returns messages to it,
+	// and a quorum queue would count those returns toward its own delivery
+	// limit and eventually drop them.
+	if _, err := DeclareClassicQueue(ch, DeadLetterQueue(queue), dlx, queue); err != nil {
+		return "", err
+	}
+	name, err := DeclareQuorumQueue(ch, queue, exchange, routingKey)
+	if err != nil {
+		return "", err
+	}
+	if err := ApplyDeadLetterPolicy(name, dlx); err != nil {
+		return "", err
+	}
+	workQueuesMu.Lock()
+	workQueues[name] = true
+	workQueuesMu.Unlock()
+	return name, nil
+}
+
+// DeliveryCount is how many times d was returned to its queue before this
+// delivery.
+func DeliveryCount(d amqp.Delivery) int {
+	switch n := d.Headers[HeaderDeliveryCount].(type) {
+	case int64:
+		return int(n)
+	case int32:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// deadLetterHeaders are the headers the broker and DeadLetter add to a
+// failed message; they describe one failure and are dropped on replay.
+var deadLetterHeaders = []string{
+	HeaderDeadLetterID, HeaderFailureReason, HeaderFailedQueue, HeaderFailedAt,
+	HeaderDeliveryCount, HeaderOriginalExchange, HeaderOriginalRoutingKey,
+	"x-death", "x-first-death-exchange", "x-first-death-queue", "x-first-death-reason",
+	"x-last-death-exchange", "x-last-death-queue", "x-last-death-reason",
+}
+
+// ReplayHeaders returns the headers of a dead letter without the ones
+// recording its failure, so a replayed message starts with a fresh delivery
+// count.
+func ReplayHeaders(h amqp.Table) amqp.Table {
+	headers := amqp.Table{}
+	for k, v := range h {
+		headers[k] = v
+	}
+	for _, k := range deadLetterHeaders {
+		delete(headers, k)
+	}
+	return headers
+}
+
+var (
+	deadLetterMu sync.Mutex
+	deadLetterCh *ConfirmChannel
+)
+
+// DeadLetter moves d to the dead-letter queue of queue, adding headers that
+// identify it and say where it came from and why and when it failed. The
+// original is acked only once the broker has confirmed the copy.
+func DeadLetter(d amqp.Delivery, exchange, queue, reason string) error {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderDeadLetterID] = uuid.NewString()
+	headers[HeaderOriginalExchange] = d.Exchange
+	headers[HeaderOriginalRoutingKey] = d.RoutingKey
+	headers[HeaderFailureReason] = reason
+	headers[HeaderFailedQueue] = queue
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderDeliveryCount] = int64(DeliveryCount(d) + 1)
+
+	if err := publishDeadLetter(DeadLetterExchange(exchange), queue, amqp.Publishing{
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Headers:      headers,
+		Body:         d.Body,
+	}); err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+// publishDeadLetter publishes on a confirm channel shared by every consumer
+// in the process, reopening it after a failure.
+func publishDeadLetter(exchange, routingKey string, msg amqp.Publishing) error {
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	if deadLetterCh == nil || deadLetterCh.IsClosed() {
+		ch, err := NewConfirmChannel()
+		if err != nil {
+			return err
+		}
+		deadLetterCh = ch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := deadLetterCh.PublishConfirmed(ctx, exchange, routingKey, msg); err != nil {
+		_ = deadLetterCh.Close()
+		deadLetterCh = nil
+		return err
+	}
+	return nil
+}
+
+// Fail settles a delivery that could not be processed. Transient failures go
+// back on the queue until the delivery limit is reached; permanent failures,
+// and transient ones out of deliveries, are dead-lettered with reason. If the
+// dead-letter publish itself fails the message is rejected, and the queue's
+// own dead-letter exchange takes it.
+func Fail(d amqp.Delivery, exchange, queue, reason string, transient bool) {
+	if transient && DeliveryCount(d)+1 < DeliveryLimit() {
+		_ = d.Nack(false, true)
+		return
+	}
+	if err := DeadLetter(d, exchange, queue, reason); err != nil {
+		log.Printf("rabbitmq: dead-letter from %s failed, rejecting: %v", queue, err)
+		_ = d.Nack(false, false)
+	}
+}
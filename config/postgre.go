@@ -2,14 +2,18 @@ package config
 
 import (
 	"ecommerce-app/domain/users/entities"
+	categoryEntities "ecommerce-app/domain/categories/entities"
 	productEntities "ecommerce-app/domain/products/entities"
 	orderEntities "ecommerce-app/domain/orders/entities"
+	inventoryEntities "ecommerce-app/domain/inventory/entities"
+	outboxEntities "ecommerce-app/domain/outbox/entities"
 	"log"
 	"os"
 	"sync"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -28,10 +32,19 @@ func GetDB() *gorm.DB {
 		if err != nil {
 			log.Fatalf("failed to connect database: %v", err)
 		}
-		err = conn.AutoMigrate(&entities.User{}, &productEntities.Product{}, &orderEntities.Order{}, &orderEntities.OrderItem{})
+		err = conn.AutoMigrate(&entities.User{}, &categoryEntities.Category{}, &productEntities.Product{}, &productEntities.ProductVariant{}, &productEntities.StockSubscription{}, &orderEntities.Order{}, &orderEntities.OrderItem{}, &orderEntities.OrderItemAllocation{}, &orderEntities.OrderStatusChange{}, &inventoryEntities.Warehouse{}, &inventoryEntities.StockLevel{}, &inventoryEntities.StockReservation{}, &inventoryEntities.StockMovement{}, &outboxEntities.OutboxMessage{})
 		if err != nil {
 			log.Fatalf("failed running migrations: %v", err)
 		}
+		if err := migrateProductSearch(conn); err != nil {
+			log.Fatalf("failed running product search migrations: %v", err)
+		}
+		if err := migrateLegacyCategories(conn); err != nil {
+			log.Fatalf("failed migrating legacy categories: %v", err)
+		}
+		if err := migrateStockLedger(conn); err != nil {
+			log.Fatalf("failed running stock ledger migrations: %v", err)
+		}
 
 		log.Println("Database connected & migrated")
 		db = conn
@@ -39,3 +52,82 @@ func GetDB() *gorm.DB {
 
 	return db
 }
+
+// migrateProductSearch adds what AutoMigrate cannot express: a generated
+// tsvector over name, category and description plus a trigram index on name
+// for typo-tolerant matching.
+func migrateProductSearch(conn *gorm.DB) error {
+	stmts := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(category, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(description, '')), 'C')
+		) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops)`,
+	}
+	for _, stmt := range stmts {
+		if err := conn.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyCategories turns the free-form products.category strings that
+// predate the categories table into root categories and links the products
+// to them. Slugs are made with categoryEntities.Slugify, the same rule the
+// API uses, so a legacy category is found by the slug of its name. Where two
+// names share a slug the first alphabetically names the category. Rows that
+// already have a category_id are left alone.
+func migrateLegacyCategories(conn *gorm.DB) error {
+	var names []string
+	if err := conn.Model(&productEntities.Product{}).
+		Where("category_id IS NULL AND trim(category) <> ''").
+		Distinct().Order("1").Pluck("trim(category)", &names).Error; err != nil {
+		return err
+	}
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			slug := categoryEntities.Slugify(name)
+			if slug == "" {
+				continue
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true, Columns: []clause.Column{{Name: "slug"}}}).
+				Create(&categoryEntities.Category{Name: name, Slug: slug}).Error; err != nil {
+				return err
+			}
+			var cat categoryEntities.Category
+			if err := tx.First(&cat, "slug = ?", slug).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&productEntities.Product{}).
+				Where("category_id IS NULL AND trim(category) = ?", name).
+				Updates(map[string]interface{}{"category_id": cat.ID, "category": cat.Name}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrateStockLedger makes stock_movements append-only at the database level.
+func migrateStockLedger(conn *gorm.DB) error {
+	stmts := []string{
+		`CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'stock_movements is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS stock_movements_append_only ON stock_movements`,
+		`CREATE TRIGGER stock_movements_append_only BEFORE UPDATE OR DELETE ON stock_movements
+		FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only()`,
+	}
+	for _, stmt := range stmts {
+		if err := conn.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
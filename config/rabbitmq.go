@@ -1,6 +1,9 @@
 package config
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -9,9 +12,8 @@ import (
 )
 
 var (
-	rabbitConn  *amqp.Connection
-	rabbitOnce  sync.Once
-	rabbitMutex sync.Mutex
+	rabbitConn *amqp.Connection
+	rabbitOnce sync.Once
 )
 
 func GetRabbitConn() *amqp.Connection {
@@ -39,6 +41,61 @@ func NewChannel() (*amqp.Channel, error) {
 	return ch, nil
 }
 
+var (
+	// ErrNotConfirmed is returned when the broker nacks a published message.
+	ErrNotConfirmed = errors.New("broker did not confirm the message")
+	// ErrUnroutable is returned when no queue is bound for a published
+	// message, which the broker would otherwise drop after confirming it.
+	ErrUnroutable = errors.New("no queue is bound for the message")
+)
+
+// ConfirmChannel is a channel in publisher-confirm mode. It is not safe for
+// concurrent publishes, since returned messages are matched to the publish
+// that is waiting.
+type ConfirmChannel struct {
+	*amqp.Channel
+	returns chan amqp.Return
+}
+
+func NewConfirmChannel() (*ConfirmChannel, error) {
+	ch, err := NewChannel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, err
+	}
+	return &ConfirmChannel{
+		Channel: ch,
+		returns: ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// PublishConfirmed publishes msg as mandatory and waits for the broker to
+// take responsibility for it. The broker sends a return before the confirm,
+// so an unroutable message is already waiting in returns once the confirm
+// arrives.
+func (c *ConfirmChannel) PublishConfirmed(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	conf, err := c.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, true, false, msg)
+	if err != nil {
+		return err
+	}
+	ok, err := conf.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	select {
+	case r := <-c.returns:
+		return fmt.Errorf("%w: exchange %q, routing key %q: %s", ErrUnroutable, exchange, routingKey, r.ReplyText)
+	default:
+	}
+	if !ok {
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
 func EnsureDirectExchange(ch *amqp.Channel, exchangeName string) error {
 	return ch.ExchangeDeclare(
 		exchangeName, 
@@ -51,10 +108,23 @@ func EnsureDirectExchange(ch *amqp.Channel, exchangeName string) error {
 	)
 }
 
+// DeclareClassicQueue declares a durable classic queue and binds it. Unlike
+// quorum queues, classic queues do not count redeliveries, so messages can be
+// returned to them any number of times.
+func DeclareClassicQueue(ch *amqp.Channel, queueName, exchangeName, routingKey string) (string, error) {
+	return declareQueue(ch, queueName, exchangeName, routingKey, "classic")
+}
 
+// DeclareQuorumQueue declares a durable quorum queue and binds it. Queue
+// arguments cannot change once a queue exists, so anything beyond the queue
+// type is set with a policy instead.
 func DeclareQuorumQueue(ch *amqp.Channel, queueName, exchangeName, routingKey string) (string, error) {
+	return declareQueue(ch, queueName, exchangeName, routingKey, "quorum")
+}
+
+func declareQueue(ch *amqp.Channel, queueName, exchangeName, routingKey, queueType string) (string, error) {
 	args := amqp.Table{
-		"x-queue-type": "quorum",
+		"x-queue-type": queueType,
 	}
 	q, err := ch.QueueDeclare(
 		queueName,
@@ -72,19 +142,3 @@ func DeclareQuorumQueue(ch *amqp.Channel, queueName, exchangeName, routingKey st
 	}
 	return q.Name, nil
 }
-
-
-func PublishJSON(ch *amqp.Channel, exchange, routingKey string, body []byte) error {
-	rabbitMutex.Lock()
-	defer rabbitMutex.Unlock()
-	return ch.Publish(
-		exchange,
-		routingKey,
-		false, 
-		false, 
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		},
-	)
-}
@@ -0,0 +1,46 @@
+package entities
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Category struct {
+	ID        string    `gorm:"primaryKey;size:36"`
+	Name      string    `gorm:"size:100;not null"`
+	Slug      string    `gorm:"uniqueIndex;size:120;not null"`
+	ParentID  *string   `gorm:"index;size:36"`
+	Parent    *Category `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (c *Category) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Slugify lowercases s and collapses every run of characters other than
+// letters and digits into a single hyphen.
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
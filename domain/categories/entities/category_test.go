@@ -0,0 +1,24 @@
+package entities
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Home Office", "home-office"},
+		{"  Tools & Hardware  ", "tools-hardware"},
+		{"home-office", "home-office"},
+		{"Kids' Toys!!", "kids-toys"},
+		{"--Leading and trailing--", "leading-and-trailing"},
+		{"Café Crème", "café-crème"},
+		{"4K TVs", "4k-tvs"},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.in); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ecommerce-app/domain/categories/models/request"
+	"ecommerce-app/domain/categories/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	uc *usecase.CategoryUsecase
+}
+
+func NewCategoryHandler(uc *usecase.CategoryUsecase) *CategoryHandler {
+	return &CategoryHandler{uc}
+}
+
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	res, err := h.uc.GetTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var req request.CreateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.CreateCategory(&req)
+	if err != nil {
+		switch err {
+		case usecase.ErrInvalidSlug, usecase.ErrParentNotFound:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case usecase.ErrSlugExists:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
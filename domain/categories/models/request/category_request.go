@@ -0,0 +1,7 @@
+package request
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Slug     string `json:"slug" binding:"max=120"`
+	ParentID string `json:"parent_id" binding:"omitempty,uuid"`
+}
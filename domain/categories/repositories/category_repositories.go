@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"ecommerce-app/domain/categories/entities"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrCategoryNotFound = errors.New("category not found")
+
+type CategoryRepository interface {
+	FindAll() ([]entities.Category, error)
+	FindByID(id string) (*entities.Category, error)
+	FindBySlug(slug string) (*entities.Category, error)
+	FindDescendantIDs(id string) ([]string, error)
+	Create(c *entities.Category) error
+}
+
+type GormCategoryRepo struct {
+	db *gorm.DB
+}
+
+func NewGormCategoryRepo(db *gorm.DB) CategoryRepository {
+	return &GormCategoryRepo{db}
+}
+
+func (r *GormCategoryRepo) FindAll() ([]entities.Category, error) {
+	var categories []entities.Category
+	err := r.db.Order("name").Find(&categories).Error
+	return categories, err
+}
+
+func (r *GormCategoryRepo) FindByID(id string) (*entities.Category, error) {
+	var c entities.Category
+	if err := r.db.First(&c, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *GormCategoryRepo) FindBySlug(slug string) (*entities.Category, error) {
+	var c entities.Category
+	if err := r.db.First(&c, "slug = ?", slug).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindDescendantIDs returns id together with the IDs of every category below
+// it in the tree.
+func (r *GormCategoryRepo) FindDescendantIDs(id string) ([]string, error) {
+	var ids []string
+	err := r.db.Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = ?
+			UNION ALL
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id FROM tree`, id).Scan(&ids).Error
+	return ids, err
+}
+
+func (r *GormCategoryRepo) Create(c *entities.Category) error {
+	return r.db.Create(c).Error
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"ecommerce-app/domain/categories/entities"
+	"ecommerce-app/domain/categories/models/request"
+	"ecommerce-app/domain/categories/models/response"
+	"ecommerce-app/domain/categories/repositories"
+	"ecommerce-app/shared/cache"
+)
+
+var ErrInvalidSlug = errors.New("slug must contain at least one letter or digit")
+var ErrSlugExists = errors.New("slug already in use")
+var ErrParentNotFound = errors.New("parent category not found")
+
+type CategoryUsecase struct {
+	repo  repositories.CategoryRepository
+	cache *cache.ProductCache
+}
+
+func NewCategoryUsecase(repo repositories.CategoryRepository, cache *cache.ProductCache) *CategoryUsecase {
+	return &CategoryUsecase{repo, cache}
+}
+
+func (uc *CategoryUsecase) GetTree() ([]response.CategoryResponse, error) {
+	categories, err := uc.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]entities.Category)
+	roots := make([]entities.Category, 0)
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	var build func(c entities.Category) response.CategoryResponse
+	build = func(c entities.Category) response.CategoryResponse {
+		node := response.CategoryResponse{ID: c.ID, Name: c.Name, Slug: c.Slug, ParentID: c.ParentID}
+		for _, child := range children[c.ID] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+
+	res := make([]response.CategoryResponse, 0, len(roots))
+	for _, r := range roots {
+		res = append(res, build(r))
+	}
+	return res, nil
+}
+
+func (uc *CategoryUsecase) CreateCategory(req *request.CreateCategoryRequest) (*response.CategoryResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	slug := req.Slug
+	if slug == "" {
+		slug = name
+	}
+	slug = entities.Slugify(slug)
+	if slug == "" {
+		return nil, ErrInvalidSlug
+	}
+	if _, err := uc.repo.FindBySlug(slug); err == nil {
+		return nil, ErrSlugExists
+	}
+
+	c := &entities.Category{Name: name, Slug: slug}
+	if req.ParentID != "" {
+		parent, err := uc.repo.FindByID(req.ParentID)
+		if err != nil {
+			if err == repositories.ErrCategoryNotFound {
+				return nil, ErrParentNotFound
+			}
+			return nil, err
+		}
+		c.ParentID = &parent.ID
+	}
+
+	if err := uc.repo.Create(c); err != nil {
+		return nil, err
+	}
+	// Listings filtered by an ancestor now also cover this category.
+	if err := uc.cache.Invalidate(context.Background()); err != nil {
+		log.Printf("categories: cache invalidation failed: %v", err)
+	}
+
+	return &response.CategoryResponse{ID: c.ID, Name: c.Name, Slug: c.Slug, ParentID: c.ParentID}, nil
+}
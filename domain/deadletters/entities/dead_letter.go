@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+// DeadLetter is one message parked in a work queue's dead-letter queue.
+// Exchange and RoutingKey are where it was originally published.
+type DeadLetter struct {
+	ID            string
+	Queue         string
+	Exchange      string
+	RoutingKey    string
+	Reason        string
+	DeliveryCount int
+	FailedAt      *time.Time
+	ContentType   string
+	Headers       map[string]interface{}
+	Body          []byte
+}
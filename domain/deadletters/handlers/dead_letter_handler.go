@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ecommerce-app/domain/deadletters/models/request"
+	"ecommerce-app/domain/deadletters/usecase"
+	"ecommerce-app/shared/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterHandler struct {
+	uc *usecase.DeadLetterUsecase
+}
+
+func NewDeadLetterHandler(uc *usecase.DeadLetterUsecase) *DeadLetterHandler {
+	return &DeadLetterHandler{uc}
+}
+
+func (h *DeadLetterHandler) Queues(c *gin.Context) {
+	res, err := h.uc.Queues()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	var req request.ListDeadLettersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.List(c.Param("queue"), &req)
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *DeadLetterHandler) Replay(c *gin.Context) {
+	var req request.SelectDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.Replay(c.Param("queue"), &req)
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *DeadLetterHandler) Purge(c *gin.Context) {
+	var req request.SelectDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.Purge(c.Param("queue"), &req)
+	if err != nil {
+		writeDeadLetterError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func writeDeadLetterError(c *gin.Context, err error) {
+	switch err {
+	case pagination.ErrInvalidLimit, usecase.ErrNothingSelected:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case usecase.ErrUnknownQueue:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,12 @@
+package request
+
+type ListDeadLettersRequest struct {
+	Limit int `form:"limit"`
+}
+
+// SelectDeadLettersRequest picks messages by ID, or every message in the
+// queue when All is set.
+type SelectDeadLettersRequest struct {
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
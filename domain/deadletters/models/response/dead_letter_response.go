@@ -0,0 +1,25 @@
+package response
+
+import "time"
+
+type DeadLetterResponse struct {
+	ID            string      `json:"id"`
+	Queue         string      `json:"queue"`
+	Exchange      string      `json:"exchange,omitempty"`
+	RoutingKey    string      `json:"routing_key,omitempty"`
+	Reason        string      `json:"reason,omitempty"`
+	DeliveryCount int         `json:"delivery_count"`
+	FailedAt      *time.Time  `json:"failed_at,omitempty"`
+	Payload       interface{} `json:"payload"`
+}
+
+type DeadLetterActionResponse struct {
+	Queue    string `json:"queue"`
+	Affected int    `json:"affected"`
+}
+
+type DeadLetterQueueResponse struct {
+	Queue           string `json:"queue"`
+	DeadLetterQueue string `json:"dead_letter_queue"`
+	Messages        int    `json:"messages"`
+}
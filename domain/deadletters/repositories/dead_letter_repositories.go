@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"ecommerce-app/config"
+	"ecommerce-app/domain/deadletters/entities"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// maxScan caps how many messages one call takes off a dead-letter queue.
+const maxScan = 1000
+
+type DeadLetterRepository interface {
+	Count(queue string) (int, error)
+	List(queue string, limit int) ([]entities.DeadLetter, error)
+	// Replay republishes the selected messages (all of them when ids is
+	// empty) to their original exchange and routing key and removes them
+	// from the dead-letter queue.
+	Replay(queue string, ids []string) (int, error)
+	// Purge drops the selected messages, or all of them when ids is empty.
+	Purge(queue string, ids []string) (int, error)
+}
+
+// AMQPDeadLetterRepo reads dead-letter queues with basic.get. Messages it
+// does not settle stay unacked until the call ends and are then returned to
+// the queue, so a scan never sees the same message twice. Dead-letter queues
+// are classic queues, so returning messages does not count against a
+// delivery limit.
+type AMQPDeadLetterRepo struct{}
+
+func NewAMQPDeadLetterRepo() DeadLetterRepository {
+	return &AMQPDeadLetterRepo{}
+}
+
+func (r *AMQPDeadLetterRepo) Count(queue string) (int, error) {
+	ch, err := config.NewChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+	q, err := ch.QueueDeclarePassive(config.DeadLetterQueue(queue), true, false, false, false, nil)
+	if err != nil {
+		return 0, err
+	}
+	return q.Messages, nil
+}
+
+func (r *AMQPDeadLetterRepo) List(queue string, limit int) ([]entities.DeadLetter, error) {
+	var out []entities.DeadLetter
+	err := scan(queue, limit, func(ch *config.ConfirmChannel, d amqp.Delivery, dl entities.DeadLetter) error {
+		out = append(out, dl)
+		return d.Nack(false, true)
+	})
+	return out, err
+}
+
+func (r *AMQPDeadLetterRepo) Replay(queue string, ids []string) (int, error) {
+	selected := idSet(ids)
+	n := 0
+	err := scan(queue, maxScan, func(ch *config.ConfirmChannel, d amqp.Delivery, dl entities.DeadLetter) error {
+		if selected != nil && !selected[dl.ID] {
+			return d.Nack(false, true)
+		}
+		headers := config.ReplayHeaders(d.Headers)
+		headers["x-replayed-at"] = time.Now().UTC().Format(time.RFC3339)
+		exchange, routingKey := dl.Exchange, dl.RoutingKey
+		if routingKey == "" {
+			// Origin unknown: deliver straight to the work queue.
+			exchange, routingKey = "", queue
+		}
+		// The dead letter is acked only once the broker has confirmed
+		// the replayed copy.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := ch.PublishConfirmed(ctx, exchange, routingKey, amqp.Publishing{
+			ContentType:  d.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    d.MessageId,
+			Headers:      headers,
+			Body:         d.Body,
+		}); err != nil {
+			return err
+		}
+		n++
+		return d.Ack(false)
+	})
+	return n, err
+}
+
+func (r *AMQPDeadLetterRepo) Purge(queue string, ids []string) (int, error) {
+	if len(ids) == 0 {
+		ch, err := config.NewChannel()
+		if err != nil {
+			return 0, err
+		}
+		defer ch.Close()
+		return ch.QueuePurge(config.DeadLetterQueue(queue), false)
+	}
+
+	selected := idSet(ids)
+	n := 0
+	err := scan(queue, maxScan, func(ch *config.ConfirmChannel, d amqp.Delivery, dl entities.DeadLetter) error {
+		if !selected[dl.ID] {
+			return d.Nack(false, true)
+		}
+		n++
+		return d.Ack(false)
+	})
+	return n, err
+}
+
+// scan takes up to limit messages off the dead-letter queue of queue, holding
+// them all unacked, then hands each to fn to settle. Closing the channel
+// returns anything fn did not settle.
+func scan(queue string, limit int, fn func(*config.ConfirmChannel, amqp.Delivery, entities.DeadLetter) error) error {
+	ch, err := config.NewConfirmChannel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	dlq := config.DeadLetterQueue(queue)
+	var deliveries []amqp.Delivery
+	for len(deliveries) < limit {
+		d, ok, err := ch.Get(dlq, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	for _, d := range deliveries {
+		if err := fn(ch, d, toDeadLetter(queue, d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toDeadLetter(queue string, d amqp.Delivery) entities.DeadLetter {
+	dl := entities.DeadLetter{
+		Queue:         queue,
+		Exchange:      headerString(d.Headers, config.HeaderOriginalExchange),
+		RoutingKey:    headerString(d.Headers, config.HeaderOriginalRoutingKey),
+		Reason:        headerString(d.Headers, config.HeaderFailureReason),
+		DeliveryCount: config.DeliveryCount(d),
+		ContentType:   d.ContentType,
+		Headers:       d.Headers,
+		Body:          d.Body,
+	}
+	if t, err := time.Parse(time.RFC3339, headerString(d.Headers, config.HeaderFailedAt)); err == nil {
+		dl.FailedAt = &t
+	}
+
+	// Messages dead-lettered by the broker itself (delivery limit reached
+	// while requeueing) only carry x-death.
+	if death := firstDeath(d.Headers); death != nil {
+		if dl.Exchange == "" {
+			dl.Exchange, _ = death["exchange"].(string)
+		}
+		if dl.RoutingKey == "" {
+			if keys, ok := death["routing-keys"].([]interface{}); ok && len(keys) > 0 {
+				dl.RoutingKey, _ = keys[0].(string)
+			}
+		}
+		if dl.Reason == "" {
+			dl.Reason, _ = death["reason"].(string)
+		}
+		if dl.FailedAt == nil {
+			if t, ok := death["time"].(time.Time); ok {
+				dl.FailedAt = &t
+			}
+		}
+	}
+
+	switch {
+	case headerString(d.Headers, config.HeaderDeadLetterID) != "":
+		dl.ID = headerString(d.Headers, config.HeaderDeadLetterID)
+	case d.MessageId != "":
+		dl.ID = d.MessageId
+	default:
+		sum := sha256.Sum256(d.Body)
+		dl.ID = hex.EncodeToString(sum[:12])
+	}
+	return dl
+}
+
+func firstDeath(h amqp.Table) amqp.Table {
+	deaths, ok := h["x-death"].([]interface{})
+	if !ok || len(deaths) == 0 {
+		return nil
+	}
+	death, _ := deaths[0].(amqp.Table)
+	return death
+}
+
+func headerString(h amqp.Table, key string) string {
+	s, _ := h[key].(string)
+	return s
+}
+
+func idSet(ids []string) map[string]bool {
+	if len(ids) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
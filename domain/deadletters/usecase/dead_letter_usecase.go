@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+
+	"ecommerce-app/config"
+	"ecommerce-app/domain/deadletters/entities"
+	"ecommerce-app/domain/deadletters/models/request"
+	"ecommerce-app/domain/deadletters/models/response"
+	"ecommerce-app/domain/deadletters/repositories"
+	"ecommerce-app/events"
+	"ecommerce-app/shared/pagination"
+)
+
+var ErrUnknownQueue = errors.New("unknown work queue")
+var ErrNothingSelected = errors.New("select messages with ids or set all")
+
+const defaultPageSize = 50
+
+type DeadLetterUsecase struct {
+	repo repositories.DeadLetterRepository
+}
+
+func NewDeadLetterUsecase(repo repositories.DeadLetterRepository) *DeadLetterUsecase {
+	return &DeadLetterUsecase{repo}
+}
+
+// Queues reports how many messages each work queue has parked.
+func (uc *DeadLetterUsecase) Queues() ([]response.DeadLetterQueueResponse, error) {
+	queues := config.WorkQueues()
+	res := make([]response.DeadLetterQueueResponse, 0, len(queues))
+	for _, q := range queues {
+		n, err := uc.repo.Count(q)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, response.DeadLetterQueueResponse{
+			Queue: q, DeadLetterQueue: config.DeadLetterQueue(q), Messages: n,
+		})
+	}
+	return res, nil
+}
+
+func (uc *DeadLetterUsecase) List(queue string, req *request.ListDeadLettersRequest) ([]response.DeadLetterResponse, error) {
+	if !config.IsWorkQueue(queue) {
+		return nil, ErrUnknownQueue
+	}
+	limit, err := pagination.Limit(req.Limit, defaultPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	letters, err := uc.repo.List(queue, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]response.DeadLetterResponse, 0, len(letters))
+	for i := range letters {
+		res = append(res, toDeadLetterResponse(&letters[i]))
+	}
+	return res, nil
+}
+
+func (uc *DeadLetterUsecase) Replay(queue string, req *request.SelectDeadLettersRequest) (*response.DeadLetterActionResponse, error) {
+	if err := validateSelection(queue, req); err != nil {
+		return nil, err
+	}
+	n, err := uc.repo.Replay(queue, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return &response.DeadLetterActionResponse{Queue: queue, Affected: n}, nil
+}
+
+func (uc *DeadLetterUsecase) Purge(queue string, req *request.SelectDeadLettersRequest) (*response.DeadLetterActionResponse, error) {
+	if err := validateSelection(queue, req); err != nil {
+		return nil, err
+	}
+	n, err := uc.repo.Purge(queue, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return &response.DeadLetterActionResponse{Queue: queue, Affected: n}, nil
+}
+
+func validateSelection(queue string, req *request.SelectDeadLettersRequest) error {
+	if !config.IsWorkQueue(queue) {
+		return ErrUnknownQueue
+	}
+	if req.All == (len(req.IDs) > 0) {
+		return ErrNothingSelected
+	}
+	return nil
+}
+
+func toDeadLetterResponse(dl *entities.DeadLetter) response.DeadLetterResponse {
+	return response.DeadLetterResponse{
+		ID:            dl.ID,
+		Queue:         dl.Queue,
+		Exchange:      dl.Exchange,
+		RoutingKey:    dl.RoutingKey,
+		Reason:        dl.Reason,
+		DeliveryCount: dl.DeliveryCount,
+		FailedAt:      dl.FailedAt,
+		Payload:       decodePayload(dl.Body),
+	}
+}
+
+// decodePayload shows an order event as its typed payload when the body
+// parses as one, and anything else as generic JSON or, failing that, text.
+func decodePayload(body []byte) interface{} {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return string(body)
+	}
+	if _, ok := probe["items"]; ok {
+		var p events.OrderPlacedPayload
+		if json.Unmarshal(body, &p) == nil {
+			return p
+		}
+	}
+	if _, ok := probe["status"]; ok {
+		if _, ok := probe["order_id"]; ok {
+			var p events.OrderResultPayload
+			if json.Unmarshal(body, &p) == nil {
+				return p
+			}
+		}
+	}
+	var generic interface{}
+	_ = json.Unmarshal(body, &generic)
+	return generic
+}
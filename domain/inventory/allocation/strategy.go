@@ -0,0 +1,141 @@
+package allocation
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	SingleWarehouseFirst = "single_first"
+	Nearest              = "nearest"
+	Split                = "split"
+)
+
+var ErrUnknownStrategy = errors.New("unknown allocation strategy")
+
+// Source is stock of one order line's item available in one warehouse.
+type Source struct {
+	WarehouseID string
+	Available   int
+	Priority    int
+	Latitude    float64
+	Longitude   float64
+}
+
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type Allocation struct {
+	WarehouseID string
+	Quantity    int
+}
+
+// Strategy decides which warehouses fulfil quantity units of one order line.
+// It returns nil when the sources cannot cover the whole quantity.
+type Strategy interface {
+	Allocate(quantity int, sources []Source, dest *Location) []Allocation
+}
+
+func New(name string) (Strategy, error) {
+	switch name {
+	case "", SingleWarehouseFirst:
+		return singleFirst{}, nil
+	case Nearest:
+		return nearest{}, nil
+	case Split:
+		return split{}, nil
+	}
+	return nil, ErrUnknownStrategy
+}
+
+// singleFirst ships the whole line from the highest-priority warehouse that
+// can, and only splits it when no single warehouse holds enough.
+type singleFirst struct{}
+
+func (singleFirst) Allocate(quantity int, sources []Source, _ *Location) []Allocation {
+	ordered := byPriority(sources)
+	if a := whole(quantity, ordered); a != nil {
+		return a
+	}
+	return greedy(quantity, ordered)
+}
+
+// nearest behaves like singleFirst but ranks warehouses by distance to the
+// destination. Without a destination it falls back to priority order.
+type nearest struct{}
+
+func (nearest) Allocate(quantity int, sources []Source, dest *Location) []Allocation {
+	ordered := byPriority(sources)
+	if dest != nil {
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return distanceKm(ordered[i], *dest) < distanceKm(ordered[j], *dest)
+		})
+	}
+	if a := whole(quantity, ordered); a != nil {
+		return a
+	}
+	return greedy(quantity, ordered)
+}
+
+// split draws from warehouses in priority order until the line is covered.
+type split struct{}
+
+func (split) Allocate(quantity int, sources []Source, _ *Location) []Allocation {
+	return greedy(quantity, byPriority(sources))
+}
+
+func byPriority(sources []Source) []Source {
+	ordered := append([]Source(nil), sources...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return ordered[i].WarehouseID < ordered[j].WarehouseID
+	})
+	return ordered
+}
+
+func whole(quantity int, ordered []Source) []Allocation {
+	for _, s := range ordered {
+		if s.Available >= quantity {
+			return []Allocation{{WarehouseID: s.WarehouseID, Quantity: quantity}}
+		}
+	}
+	return nil
+}
+
+func greedy(quantity int, ordered []Source) []Allocation {
+	var out []Allocation
+	remaining := quantity
+	for _, s := range ordered {
+		if remaining == 0 {
+			break
+		}
+		if s.Available <= 0 {
+			continue
+		}
+		take := s.Available
+		if take > remaining {
+			take = remaining
+		}
+		out = append(out, Allocation{WarehouseID: s.WarehouseID, Quantity: take})
+		remaining -= take
+	}
+	if remaining > 0 {
+		return nil
+	}
+	return out
+}
+
+func distanceKm(s Source, dest Location) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(dest.Latitude - s.Latitude)
+	dLon := toRad(dest.Longitude - s.Longitude)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(s.Latitude))*math.Cos(toRad(dest.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
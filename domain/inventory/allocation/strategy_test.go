@@ -0,0 +1,116 @@
+package allocation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Strategy
+		wantErr error
+	}{
+		{"", singleFirst{}, nil},
+		{SingleWarehouseFirst, singleFirst{}, nil},
+		{Nearest, nearest{}, nil},
+		{Split, split{}, nil},
+		{"random", nil, ErrUnknownStrategy},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.name)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf("New(%q) = %v, %v; want %v, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	// berlin is nearer Hamburg than munich, but munich has the better
+	// priority.
+	munich := Source{WarehouseID: "munich", Available: 5, Priority: 1, Latitude: 48.14, Longitude: 11.58}
+	berlin := Source{WarehouseID: "berlin", Available: 10, Priority: 2, Latitude: 52.52, Longitude: 13.40}
+	empty := Source{WarehouseID: "empty", Available: 0, Priority: 0}
+	hamburg := &Location{Latitude: 53.55, Longitude: 9.99}
+
+	tests := []struct {
+		name     string
+		strategy Strategy
+		quantity int
+		sources  []Source
+		dest     *Location
+		want     []Allocation
+	}{
+		{
+			name:     "single first takes the whole line from the best warehouse that can",
+			strategy: singleFirst{}, quantity: 4, sources: []Source{berlin, munich},
+			want: []Allocation{{"munich", 4}},
+		},
+		{
+			name:     "single first skips a better warehouse that is too small",
+			strategy: singleFirst{}, quantity: 8, sources: []Source{munich, berlin},
+			want: []Allocation{{"berlin", 8}},
+		},
+		{
+			name:     "single first splits only when no warehouse holds enough",
+			strategy: singleFirst{}, quantity: 12, sources: []Source{munich, berlin, empty},
+			want: []Allocation{{"munich", 5}, {"berlin", 7}},
+		},
+		{
+			name:     "nearest prefers the closest warehouse",
+			strategy: nearest{}, quantity: 4, sources: []Source{munich, berlin}, dest: hamburg,
+			want: []Allocation{{"berlin", 4}},
+		},
+		{
+			name:     "nearest without a destination uses priority",
+			strategy: nearest{}, quantity: 4, sources: []Source{berlin, munich},
+			want: []Allocation{{"munich", 4}},
+		},
+		{
+			name:     "nearest splits from the closest first",
+			strategy: nearest{}, quantity: 12, sources: []Source{munich, berlin}, dest: hamburg,
+			want: []Allocation{{"berlin", 10}, {"munich", 2}},
+		},
+		{
+			name:     "split draws in priority order",
+			strategy: split{}, quantity: 8, sources: []Source{berlin, munich},
+			want: []Allocation{{"munich", 5}, {"berlin", 3}},
+		},
+		{
+			name:     "ties in priority go by warehouse ID",
+			strategy: split{}, quantity: 2,
+			sources: []Source{{WarehouseID: "b", Available: 2, Priority: 1}, {WarehouseID: "a", Available: 2, Priority: 1}},
+			want:    []Allocation{{"a", 2}},
+		},
+		{
+			name:     "not enough stock anywhere",
+			strategy: split{}, quantity: 16, sources: []Source{munich, berlin},
+			want: nil,
+		},
+		{
+			name:     "no sources",
+			strategy: singleFirst{}, quantity: 1,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.strategy.Allocate(tt.quantity, tt.sources, tt.dest)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Allocate(%d) = %v, want %v", tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateDoesNotReorderSources(t *testing.T) {
+	sources := []Source{
+		{WarehouseID: "b", Available: 1, Priority: 2},
+		{WarehouseID: "a", Available: 1, Priority: 1},
+	}
+	split{}.Allocate(2, sources, nil)
+	if sources[0].WarehouseID != "b" || sources[1].WarehouseID != "a" {
+		t.Fatalf("sources were reordered: %v", sources)
+	}
+}
@@ -0,0 +1,43 @@
+package entities
+
+import "time"
+
+const (
+	MovementInitial    = "initial"
+	MovementOrder      = "order"
+	MovementRestock    = "restock"
+	MovementAdjustment = "adjustment"
+	MovementReturn     = "return"
+	// MovementReconciliation corrects drift found by the reconcile command.
+	MovementReconciliation = "reconciliation"
+	// Staff adjustments, recorded under the reason code staff gave.
+	MovementDamage     = "damage"
+	MovementLoss       = "loss"
+	MovementFound      = "found"
+	MovementCycleCount = "cycle_count"
+)
+
+// IsAdjustmentReason reports whether reason is a code staff may give for a
+// manual adjustment.
+func IsAdjustmentReason(reason string) bool {
+	switch reason {
+	case MovementDamage, MovementLoss, MovementFound, MovementCycleCount:
+		return true
+	}
+	return false
+}
+
+// StockMovement is one row of the append-only stock ledger. Delta is signed;
+// WarehouseID is empty when the change was not made at warehouse level.
+type StockMovement struct {
+	ID          string    `gorm:"primaryKey;size:36"`
+	ProductID   string    `gorm:"size:36;not null;index:idx_stock_movements_product"`
+	VariantID   string    `gorm:"size:36;not null;default:''"`
+	WarehouseID string    `gorm:"size:36;not null;default:''"`
+	Delta       int       `gorm:"not null"`
+	Reason      string    `gorm:"size:30;not null"`
+	ReferenceID string    `gorm:"size:64"`
+	Actor       string    `gorm:"size:64"`
+	Note        string    `gorm:"size:500"`
+	CreatedAt   time.Time `gorm:"not null;index:idx_stock_movements_product"`
+}
@@ -0,0 +1,25 @@
+package entities
+
+import "time"
+
+const (
+	ReservationActive    = "ACTIVE"
+	ReservationCommitted = "COMMITTED"
+	ReservationReleased  = "RELEASED"
+)
+
+// StockReservation holds stock for a pending order until ExpiresAt. Active,
+// unexpired holds are subtracted from what other orders may take; the
+// inventory worker commits them when it decrements stock and the sweeper
+// releases the ones that expire first.
+type StockReservation struct {
+	ID        string    `gorm:"primaryKey;size:36"`
+	OrderID   string    `gorm:"index;size:36;not null"`
+	ProductID string    `gorm:"size:36;not null;index:idx_stock_reservations_item"`
+	VariantID string    `gorm:"size:36;not null;default:'';index:idx_stock_reservations_item"`
+	Quantity  int       `gorm:"not null"`
+	Status    string    `gorm:"size:20;not null;index:idx_stock_reservations_item"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
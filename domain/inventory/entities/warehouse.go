@@ -0,0 +1,48 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Warehouse is a location stock ships from. Lower Priority values are tried
+// first by the priority-based allocation strategies.
+type Warehouse struct {
+	ID        string  `gorm:"primaryKey;size:36"`
+	Code      string  `gorm:"uniqueIndex;size:32;not null"`
+	Name      string  `gorm:"size:100;not null"`
+	Latitude  float64 `gorm:"not null;default:0"`
+	Longitude float64 `gorm:"not null;default:0"`
+	Priority  int     `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (w *Warehouse) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// StockLevel is the quantity of one product (or one of its variants) held in
+// one warehouse. VariantID is empty for products without variants.
+type StockLevel struct {
+	ID          string     `gorm:"primaryKey;size:36"`
+	WarehouseID string     `gorm:"size:36;not null;uniqueIndex:idx_stock_levels_item"`
+	Warehouse   *Warehouse `gorm:"foreignKey:WarehouseID;constraint:OnDelete:RESTRICT"`
+	ProductID   string     `gorm:"size:36;not null;uniqueIndex:idx_stock_levels_item;index"`
+	VariantID   string     `gorm:"size:36;not null;default:'';uniqueIndex:idx_stock_levels_item"`
+	Quantity    int        `gorm:"not null;default:0"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (l *StockLevel) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	return nil
+}
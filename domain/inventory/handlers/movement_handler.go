@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ecommerce-app/domain/inventory/models/request"
+	"ecommerce-app/domain/inventory/usecase"
+	"ecommerce-app/shared/pagination"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MovementHandler struct {
+	uc *usecase.MovementUsecase
+}
+
+func NewMovementHandler(uc *usecase.MovementUsecase) *MovementHandler {
+	return &MovementHandler{uc}
+}
+
+func (h *MovementHandler) GetMovements(c *gin.Context) {
+	var req request.ListMovementsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.GetMovements(c.Param("id"), &req)
+	if err != nil {
+		switch err {
+		case pagination.ErrInvalidLimit, pagination.ErrInvalidCursor:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ecommerce-app/domain/inventory/models/request"
+	"ecommerce-app/domain/inventory/usecase"
+	"ecommerce-app/shared/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StockHandler struct {
+	uc *usecase.StockUsecase
+}
+
+func NewStockHandler(uc *usecase.StockUsecase) *StockHandler {
+	return &StockHandler{uc}
+}
+
+func (h *StockHandler) Restock(c *gin.Context) {
+	var req request.RestockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.Restock(c.Param("id"), &req, middleware.Actor(c))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *StockHandler) Adjust(c *gin.Context) {
+	var req request.AdjustStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.Adjust(c.Param("id"), &req, middleware.Actor(c))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ecommerce-app/domain/inventory/models/request"
+	"ecommerce-app/domain/inventory/repositories"
+	"ecommerce-app/domain/inventory/usecase"
+	productRepositories "ecommerce-app/domain/products/repositories"
+	"ecommerce-app/shared/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WarehouseHandler struct {
+	uc *usecase.WarehouseUsecase
+}
+
+func NewWarehouseHandler(uc *usecase.WarehouseUsecase) *WarehouseHandler {
+	return &WarehouseHandler{uc}
+}
+
+func (h *WarehouseHandler) CreateWarehouse(c *gin.Context) {
+	var req request.CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.CreateWarehouse(&req)
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *WarehouseHandler) GetWarehouses(c *gin.Context) {
+	res, err := h.uc.GetWarehouses()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *WarehouseHandler) GetStockLevels(c *gin.Context) {
+	res, err := h.uc.GetStockLevels(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *WarehouseHandler) SetStockLevel(c *gin.Context) {
+	var req request.SetStockLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.SetStockLevel(c.Param("id"), &req, middleware.Actor(c))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func writeInventoryError(c *gin.Context, err error) {
+	switch err {
+	case usecase.ErrInvalidWarehouseCode, repositories.ErrVariantRequired,
+		usecase.ErrInvalidAdjustment, usecase.ErrInvalidReason, usecase.ErrAdjustmentDirection,
+		usecase.ErrWarehouseRequired:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case repositories.ErrWarehouseNotFound, productRepositories.ErrProductNotFound, productRepositories.ErrVariantNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case productRepositories.ErrInsufficientStock, repositories.ErrWarehouseCodeTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,39 @@
+package ledger
+
+import (
+	"time"
+
+	"ecommerce-app/domain/inventory/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Entry says why stock is changing and who changed it. Every write to a
+// stock column passes one so the change can be recorded next to it.
+type Entry struct {
+	Reason      string
+	ReferenceID string
+	Actor       string
+	Note        string
+}
+
+// Record appends a movement inside the caller's transaction. Zero deltas are
+// not recorded.
+func Record(tx *gorm.DB, productID, variantID, warehouseID string, delta int, e Entry) error {
+	if delta == 0 {
+		return nil
+	}
+	return tx.Create(&entities.StockMovement{
+		ID:          uuid.NewString(),
+		ProductID:   productID,
+		VariantID:   variantID,
+		WarehouseID: warehouseID,
+		Delta:       delta,
+		Reason:      e.Reason,
+		ReferenceID: e.ReferenceID,
+		Actor:       e.Actor,
+		Note:        e.Note,
+		CreatedAt:   time.Now(),
+	}).Error
+}
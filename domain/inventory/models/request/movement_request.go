@@ -0,0 +1,6 @@
+package request
+
+type ListMovementsRequest struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
@@ -0,0 +1,20 @@
+package request
+
+type RestockRequest struct {
+	Quantity    int    `json:"quantity" binding:"required,min=1"`
+	VariantID   string `json:"variant_id,omitempty" binding:"omitempty,uuid"`
+	WarehouseID string `json:"warehouse_id,omitempty" binding:"omitempty,uuid"`
+	Note        string `json:"note,omitempty" binding:"max=500"`
+}
+
+// AdjustStockRequest takes either a signed Quantity or the Counted quantity
+// found on the shelf, never both. WarehouseID is required, here and on a
+// restock, once the item is stocked in any warehouse.
+type AdjustStockRequest struct {
+	Reason      string `json:"reason" binding:"required"`
+	Quantity    *int   `json:"quantity,omitempty"`
+	Counted     *int   `json:"counted,omitempty" binding:"omitempty,min=0"`
+	VariantID   string `json:"variant_id,omitempty" binding:"omitempty,uuid"`
+	WarehouseID string `json:"warehouse_id,omitempty" binding:"omitempty,uuid"`
+	Note        string `json:"note" binding:"required,max=500"`
+}
@@ -0,0 +1,15 @@
+package request
+
+type CreateWarehouseRequest struct {
+	Code      string  `json:"code" binding:"required,max=32"`
+	Name      string  `json:"name" binding:"required,max=100"`
+	Latitude  float64 `json:"latitude" binding:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" binding:"min=-180,max=180"`
+	Priority  int     `json:"priority"`
+}
+
+type SetStockLevelRequest struct {
+	ProductID string `json:"product_id" binding:"required,uuid"`
+	VariantID string `json:"variant_id,omitempty" binding:"omitempty,uuid"`
+	Quantity  int    `json:"quantity" binding:"min=0"`
+}
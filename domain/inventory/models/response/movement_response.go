@@ -0,0 +1,21 @@
+package response
+
+import "time"
+
+type MovementResponse struct {
+	ID          string    `json:"id"`
+	ProductID   string    `json:"product_id"`
+	VariantID   string    `json:"variant_id,omitempty"`
+	WarehouseID string    `json:"warehouse_id,omitempty"`
+	Delta       int       `json:"delta"`
+	Reason      string    `json:"reason"`
+	ReferenceID string    `json:"reference_id,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type MovementListResponse struct {
+	Items      []MovementResponse `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,11 @@
+package response
+
+type StockChangeResponse struct {
+	ProductID    string `json:"product_id"`
+	VariantID    string `json:"variant_id,omitempty"`
+	WarehouseID  string `json:"warehouse_id,omitempty"`
+	Reason       string `json:"reason"`
+	Delta        int    `json:"delta"`
+	ProductStock int    `json:"product_stock"`
+	VariantStock *int   `json:"variant_stock,omitempty"`
+}
@@ -0,0 +1,21 @@
+package response
+
+import "time"
+
+type WarehouseResponse struct {
+	ID        string    `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type StockLevelResponse struct {
+	WarehouseID string    `json:"warehouse_id"`
+	ProductID   string    `json:"product_id"`
+	VariantID   string    `json:"variant_id,omitempty"`
+	Quantity    int       `json:"quantity"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
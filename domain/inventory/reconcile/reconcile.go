@@ -0,0 +1,309 @@
+// Package reconcile recomputes expected stock from what the system says
+// happened and compares it with the stock columns.
+//
+// Expected stock for an item is every ledger movement that is not an order
+// (initial stock, restocks, adjustments, returns) minus the units taken by
+// order lines. Reconciliation entries themselves are left out, so applying a
+// correction does not move the expectation.
+package reconcile
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	"ecommerce-app/domain/inventory/repositories"
+	orderEntities "ecommerce-app/domain/orders/entities"
+	prodEntities "ecommerce-app/domain/products/entities"
+	productRepo "ecommerce-app/domain/products/repositories"
+	"ecommerce-app/shared/concurrency"
+
+	"gorm.io/gorm"
+)
+
+const (
+	StatusOK        = "ok"
+	StatusDrift     = "drift"
+	StatusUntracked = "untracked"
+)
+
+// Line compares the recorded stock of one product, or of one variant when
+// VariantID is set, with the expected stock. Drift is Recorded - Expected.
+// Untracked items have no ledger history to reconcile against.
+type Line struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	Recorded  int    `json:"recorded"`
+	Expected  int    `json:"expected"`
+	Drift     int    `json:"drift"`
+	Status    string `json:"status"`
+}
+
+type itemKey struct {
+	ProductID string
+	VariantID string
+}
+
+type sumRow struct {
+	ProductID string
+	VariantID string
+	Total     int
+	Movements int
+}
+
+// Report reconciles every product and variant, or a single product when
+// productID is set. Products come before their variants.
+func Report(db *gorm.DB, productID string) ([]Line, error) {
+	var products []prodEntities.Product
+	q := db.Order("name, id")
+	if productID != "" {
+		q = q.Where("id = ?", productID)
+	}
+	if err := q.Find(&products).Error; err != nil {
+		return nil, err
+	}
+	var variants []prodEntities.ProductVariant
+	vq := db.Order("sku, id")
+	if productID != "" {
+		vq = vq.Where("product_id = ?", productID)
+	}
+	if err := vq.Find(&variants).Error; err != nil {
+		return nil, err
+	}
+
+	movements, err := movementSums(db, productID)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := orderSums(db, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	byProduct := make(map[string][]prodEntities.ProductVariant)
+	for _, v := range variants {
+		byProduct[v.ProductID] = append(byProduct[v.ProductID], v)
+	}
+
+	var lines []Line
+	for _, p := range products {
+		expected, history := productExpected(movements, taken, p.ID)
+		productLine := Line{ProductID: p.ID, Name: p.Name, Recorded: p.Stock, Expected: expected}
+		lines = append(lines, settle(productLine, history))
+
+		for _, v := range byProduct[p.ID] {
+			k := itemKey{ProductID: p.ID, VariantID: v.ID}
+			m := movements[k]
+			line := Line{
+				ProductID: p.ID,
+				VariantID: v.ID,
+				Name:      fmt.Sprintf("%s / %s", p.Name, v.SKU),
+				Recorded:  v.Stock,
+				Expected:  m.Total - taken[k],
+			}
+			lines = append(lines, settle(line, m.Movements))
+		}
+	}
+	return lines, nil
+}
+
+// productExpected totals the expected stock of a product across all of its
+// items, along with how many movements that is based on.
+func productExpected(movements map[itemKey]sumRow, taken map[itemKey]int, productID string) (expected, history int) {
+	for k, m := range movements {
+		if k.ProductID == productID {
+			expected += m.Total
+			history += m.Movements
+		}
+	}
+	for k, n := range taken {
+		if k.ProductID == productID {
+			expected -= n
+		}
+	}
+	return expected, history
+}
+
+func settle(l Line, history int) Line {
+	l.Drift = l.Recorded - l.Expected
+	switch {
+	case history == 0 && l.Recorded != 0:
+		l.Status = StatusUntracked
+	case l.Drift != 0:
+		l.Status = StatusDrift
+	default:
+		l.Status = StatusOK
+	}
+	return l
+}
+
+func movementSums(db *gorm.DB, productID string) (map[itemKey]sumRow, error) {
+	var rows []sumRow
+	q := db.Model(&entities.StockMovement{}).
+		Select("product_id, variant_id, COALESCE(SUM(delta), 0) AS total, COUNT(*) AS movements").
+		Where("reason NOT IN ?", []string{entities.MovementOrder, entities.MovementReconciliation})
+	if productID != "" {
+		q = q.Where("product_id = ?", productID)
+	}
+	if err := q.Group("product_id, variant_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[itemKey]sumRow, len(rows))
+	for _, r := range rows {
+		out[itemKey{r.ProductID, r.VariantID}] = r
+	}
+	return out, nil
+}
+
+// orderSums totals the units order lines have taken out of stock. Lines from
+// before fulfilment was tracked per line count in full once their order was
+// confirmed, including orders that have since shipped.
+func orderSums(db *gorm.DB, productID string) (map[itemKey]int, error) {
+	tookStock := []orderEntities.OrderStatus{
+		orderEntities.StatusConfirmed, orderEntities.StatusShipped,
+		orderEntities.StatusDelivered, orderEntities.StatusRefunded,
+	}
+	var rows []sumRow
+	q := db.Table("order_items").
+		Select(`order_items.product_id, COALESCE(order_items.variant_id, '') AS variant_id,
+			COALESCE(SUM(CASE WHEN order_items.status = ? AND orders.status IN ? THEN order_items.quantity
+				ELSE order_items.fulfilled_quantity END), 0) AS total`,
+			orderEntities.ItemPending, tookStock).
+		Joins("JOIN orders ON orders.id = order_items.order_id")
+	if productID != "" {
+		q = q.Where("order_items.product_id = ?", productID)
+	}
+	if err := q.Group("order_items.product_id, COALESCE(order_items.variant_id, '')").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[itemKey]int, len(rows))
+	for _, r := range rows {
+		out[itemKey{r.ProductID, r.VariantID}] = r.Total
+	}
+	return out, nil
+}
+
+// Apply corrects every product the report found drifting and records each
+// correction in the ledger as a reconciliation movement. Each product is
+// corrected in its own transaction, variants before the product total, under
+// the same row locks as other stock writes. The report was taken without
+// locks, so expected stock is computed again once the rows are locked and an
+// item is only written if it still differs; orders placed or cancelled since
+// the report are not undone. Untracked items are left alone. It returns the
+// number of corrections written.
+func Apply(db *gorm.DB, lines []Line, actor string) (int, error) {
+	byProduct := make(map[string][]Line)
+	drifted := make(map[string]bool)
+	for _, l := range lines {
+		if l.Status == StatusUntracked {
+			continue
+		}
+		byProduct[l.ProductID] = append(byProduct[l.ProductID], l)
+		if l.Status == StatusDrift {
+			drifted[l.ProductID] = true
+		}
+	}
+	ids := make([]string, 0, len(drifted))
+	for id := range drifted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	applied := 0
+	for _, id := range ids {
+		n, err := applyProduct(db, id, byProduct[id], actor)
+		if err != nil {
+			return applied, fmt.Errorf("product %s: %w", id, err)
+		}
+		applied += n
+	}
+	return applied, nil
+}
+
+func applyProduct(db *gorm.DB, productID string, lines []Line, actor string) (int, error) {
+	applied := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		applied = 0
+		items := []repositories.StockItem{{ProductID: productID}}
+		for _, l := range lines {
+			if l.VariantID != "" {
+				items = append(items, repositories.StockItem{ProductID: productID, VariantID: l.VariantID})
+			}
+		}
+		if err := repositories.LockItems(tx, items); err != nil {
+			return err
+		}
+		var prod prodEntities.Product
+		if err := tx.First(&prod, "id = ?", productID).Error; err != nil {
+			return err
+		}
+		movements, err := movementSums(tx, productID)
+		if err != nil {
+			return err
+		}
+		taken, err := orderSums(tx, productID)
+		if err != nil {
+			return err
+		}
+
+		correctProduct := false
+		for _, l := range lines {
+			if l.VariantID == "" {
+				correctProduct = true
+				continue
+			}
+			k := itemKey{ProductID: productID, VariantID: l.VariantID}
+			if movements[k].Movements == 0 {
+				continue
+			}
+			var variant prodEntities.ProductVariant
+			if err := tx.First(&variant, "id = ? AND product_id = ?", l.VariantID, productID).Error; err != nil {
+				return err
+			}
+			expected := movements[k].Total - taken[k]
+			delta := expected - variant.Stock
+			if delta == 0 {
+				continue
+			}
+			if err := ledger.Record(tx, productID, l.VariantID, "", delta, entry(actor, variant.Stock, expected)); err != nil {
+				return err
+			}
+			log.Printf("reconcile: %s / %s: stock %d -> %d (%+d)", productID, l.VariantID, variant.Stock, expected, delta)
+			variant.Stock = expected
+			prod.Stock += delta
+			if err := tx.Save(&variant).Error; err != nil {
+				return err
+			}
+			applied++
+		}
+
+		if expected, history := productExpected(movements, taken, productID); correctProduct && history > 0 && prod.Stock != expected {
+			delta := expected - prod.Stock
+			if err := ledger.Record(tx, productID, "", "", delta, entry(actor, prod.Stock, expected)); err != nil {
+				return err
+			}
+			log.Printf("reconcile: %s: stock %d -> %d (%+d)", productID, prod.Stock, expected, delta)
+			prod.Stock = expected
+			applied++
+		}
+		if applied == 0 {
+			return nil
+		}
+		if err := concurrency.Update(tx, "product", prod.ID, &prod, &prod.Version); err != nil {
+			return err
+		}
+		return productRepo.TrackLowStock(tx, &prod)
+	})
+	return applied, err
+}
+
+func entry(actor string, recorded, expected int) ledger.Entry {
+	return ledger.Entry{
+		Reason: entities.MovementReconciliation,
+		Actor:  actor,
+		Note:   fmt.Sprintf("reconciled: recorded %d, expected %d", recorded, expected),
+	}
+}
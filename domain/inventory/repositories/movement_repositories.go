@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"time"
+
+	"ecommerce-app/domain/inventory/entities"
+
+	"gorm.io/gorm"
+)
+
+// MovementCursor is the last movement of a page; the next page holds older
+// movements.
+type MovementCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+type MovementRepository interface {
+	FindByProduct(productID string, limit int, before *MovementCursor) ([]entities.StockMovement, error)
+}
+
+type GormMovementRepo struct {
+	db *gorm.DB
+}
+
+func NewGormMovementRepo(db *gorm.DB) MovementRepository {
+	return &GormMovementRepo{db}
+}
+
+func (r *GormMovementRepo) FindByProduct(productID string, limit int, before *MovementCursor) ([]entities.StockMovement, error) {
+	var movements []entities.StockMovement
+	q := r.db.Where("product_id = ?", productID)
+	if before != nil {
+		q = q.Where("(created_at, id) < (?, ?)", before.CreatedAt, before.ID)
+	}
+	err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&movements).Error
+	return movements, err
+}
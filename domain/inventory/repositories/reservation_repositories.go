@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"ecommerce-app/domain/inventory/entities"
+	prodEntities "ecommerce-app/domain/products/entities"
+	productRepo "ecommerce-app/domain/products/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationLine is one line to hold stock for. AllowShort lines may be held
+// for less than Quantity (down to nothing) instead of failing the order.
+type ReservationLine struct {
+	ProductID  string
+	VariantID  string
+	Quantity   int
+	AllowShort bool
+}
+
+type reservationKey struct {
+	ProductID string
+	VariantID string
+}
+
+type reservationNeed struct {
+	strict int
+	short  int
+}
+
+// Reserve places holds for every line of an order inside the caller's
+// transaction. It fails with ErrInsufficientStock when stock, or what the
+// warehouses hold if that is less, minus the other active holds cannot cover
+// the strict lines of an item; nothing is held in that case once the caller
+// rolls back. AllowShort lines take whatever is left after the strict ones.
+func Reserve(tx *gorm.DB, orderID string, lines []ReservationLine, expiresAt time.Time) error {
+	merged := make(map[reservationKey]*reservationNeed)
+	for _, l := range lines {
+		k := reservationKey{ProductID: l.ProductID, VariantID: l.VariantID}
+		need, ok := merged[k]
+		if !ok {
+			need = &reservationNeed{}
+			merged[k] = need
+		}
+		if l.AllowShort {
+			need.short += l.Quantity
+		} else {
+			need.strict += l.Quantity
+		}
+	}
+	keys := make([]reservationKey, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductID != keys[j].ProductID {
+			return keys[i].ProductID < keys[j].ProductID
+		}
+		return keys[i].VariantID < keys[j].VariantID
+	})
+	items := make([]StockItem, 0, len(keys))
+	for _, k := range keys {
+		items = append(items, StockItem{ProductID: k.ProductID, VariantID: k.VariantID})
+	}
+	if err := LockItems(tx, items); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		need := merged[k]
+		stock, err := lockStock(tx, k.ProductID, k.VariantID)
+		if err != nil {
+			return err
+		}
+		held, err := HeldQuantity(tx, k.ProductID, k.VariantID, "")
+		if err != nil {
+			return err
+		}
+		free := stock - held
+		if free < need.strict {
+			return productRepo.ErrInsufficientStock
+		}
+		qty := need.strict + min(need.short, free-need.strict)
+		if qty <= 0 {
+			continue
+		}
+		if err := tx.Create(&entities.StockReservation{
+			ID:        uuid.NewString(),
+			OrderID:   orderID,
+			ProductID: k.ProductID,
+			VariantID: k.VariantID,
+			Quantity:  qty,
+			Status:    entities.ReservationActive,
+			ExpiresAt: expiresAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HeldQuantity sums the active, unexpired holds on one product or variant,
+// ignoring those that belong to excludeOrderID.
+func HeldQuantity(tx *gorm.DB, productID, variantID, excludeOrderID string) (int, error) {
+	var held int
+	q := tx.Model(&entities.StockReservation{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("product_id = ? AND variant_id = ? AND status = ? AND expires_at > ?",
+			productID, variantID, entities.ReservationActive, time.Now())
+	if excludeOrderID != "" {
+		q = q.Where("order_id <> ?", excludeOrderID)
+	}
+	err := q.Scan(&held).Error
+	return held, err
+}
+
+func CommitReservations(tx *gorm.DB, orderID string) error {
+	return setReservationStatus(tx, orderID, entities.ReservationCommitted)
+}
+
+func ReleaseReservations(tx *gorm.DB, orderID string) error {
+	return setReservationStatus(tx, orderID, entities.ReservationReleased)
+}
+
+// ReleaseExpired releases every active hold whose expiry has passed and
+// reports how many were released.
+func ReleaseExpired(db *gorm.DB, now time.Time) (int64, error) {
+	res := db.Model(&entities.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", entities.ReservationActive, now).
+		Update("status", entities.ReservationReleased)
+	return res.RowsAffected, res.Error
+}
+
+func setReservationStatus(tx *gorm.DB, orderID, status string) error {
+	return tx.Model(&entities.StockReservation{}).
+		Where("order_id = ? AND status = ?", orderID, entities.ReservationActive).
+		Update("status", status).Error
+}
+
+// lockStock locks the product row (and the variant row, if any) and returns
+// the stock of the item being reserved. When the item is stocked in
+// warehouses that is capped at what the warehouse levels hold, since the
+// inventory worker allocates no more than that.
+func lockStock(tx *gorm.DB, productID, variantID string) (int, error) {
+	var prod prodEntities.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, productRepo.ErrProductNotFound
+		}
+		return 0, err
+	}
+	stock := prod.Stock
+	if variantID != "" {
+		var variant prodEntities.ProductVariant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ? AND product_id = ?", variantID, productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, productRepo.ErrVariantNotFound
+			}
+			return 0, err
+		}
+		stock = variant.Stock
+	}
+
+	levels, err := LockStockLevels(tx, productID, variantID)
+	if err != nil {
+		return 0, err
+	}
+	if len(levels) == 0 {
+		return stock, nil
+	}
+	var inWarehouses int
+	for _, l := range levels {
+		inWarehouses += max(l.Quantity, 0)
+	}
+	return min(stock, inWarehouses), nil
+}
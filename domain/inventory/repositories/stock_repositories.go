@@ -0,0 +1,224 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+
+	"ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	prodEntities "ecommerce-app/domain/products/entities"
+	productRepo "ecommerce-app/domain/products/repositories"
+	"ecommerce-app/events"
+	"ecommerce-app/shared/concurrency"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StockChange is one signed change to the stock of a product or variant,
+// optionally pinned to a warehouse.
+type StockChange struct {
+	ProductID   string
+	VariantID   string
+	WarehouseID string
+	Delta       int
+}
+
+// StockResult reports stock before and after a change for the product total
+// and, when a variant was named, for the variant.
+type StockResult struct {
+	ProductBefore int
+	ProductAfter  int
+	VariantBefore int
+	VariantAfter  int
+}
+
+// ApplyStockChange applies c inside the caller's transaction and records it in
+// the ledger. Rows are locked product, then variant, then warehouse level, the
+// same order the inventory worker uses. A change that would take any of them
+// below zero fails with ErrInsufficientStock.
+func ApplyStockChange(tx *gorm.DB, c StockChange, e ledger.Entry) (*StockResult, error) {
+	var prod prodEntities.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", c.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, productRepo.ErrProductNotFound
+		}
+		return nil, err
+	}
+	var variant prodEntities.ProductVariant
+	if c.VariantID != "" {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ? AND product_id = ?", c.VariantID, c.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, productRepo.ErrVariantNotFound
+			}
+			return nil, err
+		}
+	} else {
+		var variants int64
+		if err := tx.Model(&prodEntities.ProductVariant{}).Where("product_id = ?", c.ProductID).Count(&variants).Error; err != nil {
+			return nil, err
+		}
+		if variants > 0 {
+			return nil, ErrVariantRequired
+		}
+	}
+
+	res := &StockResult{
+		ProductBefore: prod.Stock,
+		ProductAfter:  prod.Stock + c.Delta,
+		VariantBefore: variant.Stock,
+		VariantAfter:  variant.Stock + c.Delta,
+	}
+	if res.ProductAfter < 0 || (c.VariantID != "" && res.VariantAfter < 0) {
+		return nil, productRepo.ErrInsufficientStock
+	}
+
+	if c.WarehouseID != "" {
+		if err := tx.First(&entities.Warehouse{}, "id = ?", c.WarehouseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrWarehouseNotFound
+			}
+			return nil, err
+		}
+		var level entities.StockLevel
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("warehouse_id = ? AND product_id = ? AND variant_id = ?", c.WarehouseID, c.ProductID, c.VariantID).
+			First(&level).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if level.Quantity+c.Delta < 0 {
+			return nil, productRepo.ErrInsufficientStock
+		}
+		level.WarehouseID = c.WarehouseID
+		level.ProductID = c.ProductID
+		level.VariantID = c.VariantID
+		level.Quantity += c.Delta
+		if err := tx.Save(&level).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	prod.Stock = res.ProductAfter
+	if err := concurrency.Update(tx, "product", prod.ID, &prod, &prod.Version); err != nil {
+		return nil, err
+	}
+	if c.VariantID != "" {
+		variant.Stock = res.VariantAfter
+		if err := tx.Save(&variant).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ledger.Record(tx, c.ProductID, c.VariantID, c.WarehouseID, c.Delta, e); err != nil {
+		return nil, err
+	}
+	if err := enqueueStockEvents(tx, c, res); err != nil {
+		return nil, err
+	}
+	if err := productRepo.TrackLowStock(tx, &prod); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// enqueueStockEvents queues what a stock change announces in the outbox:
+// restocked when units were added, and back-in-stock for the product and the
+// variant when either went from none to some.
+func enqueueStockEvents(tx *gorm.DB, c StockChange, r *StockResult) error {
+	if err := events.EnqueueRestocked(tx, c.ProductID, c.VariantID, r.ProductAfter-r.ProductBefore); err != nil {
+		return err
+	}
+	if err := events.EnqueueIfBackInStock(tx, c.ProductID, "", r.ProductBefore, r.ProductAfter); err != nil {
+		return err
+	}
+	if c.VariantID == "" {
+		return nil
+	}
+	return events.EnqueueIfBackInStock(tx, c.ProductID, c.VariantID, r.VariantBefore, r.VariantAfter)
+}
+
+// CountedDelta returns the change that brings the stock c points at, the
+// warehouse level when WarehouseID is set, else the variant or product, to
+// counted. Lock the rows first so the count is not overtaken before it is
+// applied.
+func CountedDelta(tx *gorm.DB, c StockChange, counted int) (int, error) {
+	var current int
+	var err error
+	switch {
+	case c.WarehouseID != "":
+		var level entities.StockLevel
+		err = tx.Where("warehouse_id = ? AND product_id = ? AND variant_id = ?", c.WarehouseID, c.ProductID, c.VariantID).
+			First(&level).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		current = level.Quantity
+	case c.VariantID != "":
+		var variant prodEntities.ProductVariant
+		err = tx.First(&variant, "id = ? AND product_id = ?", c.VariantID, c.ProductID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, productRepo.ErrVariantNotFound
+		}
+		current = variant.Stock
+	default:
+		var prod prodEntities.Product
+		err = tx.First(&prod, "id = ?", c.ProductID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, productRepo.ErrProductNotFound
+		}
+		current = prod.Stock
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counted - current, nil
+}
+
+// StockItem names a product, or one of its variants when VariantID is set.
+type StockItem struct {
+	ProductID string
+	VariantID string
+}
+
+// LockItems locks the product, variant and warehouse level rows of every item
+// up front with one query per table, each in key order. Transactions touching
+// overlapping items then queue on the first row they share instead of each
+// holding a row the other is waiting for. Later FOR UPDATE reads of the same
+// rows in the transaction return at once.
+func LockItems(tx *gorm.DB, items []StockItem) error {
+	productIDs := make([]string, 0, len(items))
+	variantIDs := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items)*2)
+	for _, it := range items {
+		if !seen[it.ProductID] {
+			seen[it.ProductID] = true
+			productIDs = append(productIDs, it.ProductID)
+		}
+		if it.VariantID != "" && !seen[it.VariantID] {
+			seen[it.VariantID] = true
+			variantIDs = append(variantIDs, it.VariantID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil
+	}
+	sort.Strings(productIDs)
+	sort.Strings(variantIDs)
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", productIDs).Order("id").
+		Find(&[]prodEntities.Product{}).Error; err != nil {
+		return err
+	}
+	if len(variantIDs) > 0 {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", variantIDs).Order("id").
+			Find(&[]prodEntities.ProductVariant{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id IN ?", productIDs).Order("product_id, variant_id, warehouse_id").
+		Find(&[]entities.StockLevel{}).Error
+}
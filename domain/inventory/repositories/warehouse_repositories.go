@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"errors"
+
+	"ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	prodEntities "ecommerce-app/domain/products/entities"
+	productRepo "ecommerce-app/domain/products/repositories"
+	"ecommerce-app/shared/concurrency"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrWarehouseNotFound = errors.New("warehouse not found")
+var ErrWarehouseCodeTaken = errors.New("warehouse code already in use")
+var ErrVariantRequired = errors.New("product has variants; variant_id is required")
+
+// uniqueViolation is the SQLSTATE postgres reports for a duplicate key.
+const uniqueViolation = "23505"
+
+type WarehouseRepository interface {
+	Create(w *entities.Warehouse) error
+	FindAll() ([]entities.Warehouse, error)
+	FindByID(id string) (*entities.Warehouse, error)
+	FindStockLevels(productID string) ([]entities.StockLevel, error)
+	SetStockLevel(warehouseID, productID, variantID string, quantity int, e ledger.Entry) (*entities.StockLevel, *StockResult, error)
+}
+
+type GormWarehouseRepo struct {
+	db *gorm.DB
+}
+
+func NewGormWarehouseRepo(db *gorm.DB) WarehouseRepository {
+	return &GormWarehouseRepo{db}
+}
+
+// Create inserts w, failing with ErrWarehouseCodeTaken when another
+// warehouse already has its code.
+func (r *GormWarehouseRepo) Create(w *entities.Warehouse) error {
+	err := r.db.Create(w).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrWarehouseCodeTaken
+	}
+	return err
+}
+
+func (r *GormWarehouseRepo) FindAll() ([]entities.Warehouse, error) {
+	var warehouses []entities.Warehouse
+	err := r.db.Order("priority, code").Find(&warehouses).Error
+	return warehouses, err
+}
+
+func (r *GormWarehouseRepo) FindByID(id string) (*entities.Warehouse, error) {
+	var w entities.Warehouse
+	if err := r.db.First(&w, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWarehouseNotFound
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *GormWarehouseRepo) FindStockLevels(productID string) ([]entities.StockLevel, error) {
+	var levels []entities.StockLevel
+	err := r.db.Preload("Warehouse").Where("product_id = ?", productID).
+		Order("variant_id, warehouse_id").Find(&levels).Error
+	return levels, err
+}
+
+// SetStockLevel records a counted quantity for one warehouse and moves the
+// product (and variant) totals by the same difference, all under row locks.
+func (r *GormWarehouseRepo) SetStockLevel(warehouseID, productID, variantID string, quantity int, e ledger.Entry) (*entities.StockLevel, *StockResult, error) {
+	var level entities.StockLevel
+	var res StockResult
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&entities.Warehouse{}, "id = ?", warehouseID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrWarehouseNotFound
+			}
+			return err
+		}
+
+		var prod prodEntities.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", productID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return productRepo.ErrProductNotFound
+			}
+			return err
+		}
+		var variant prodEntities.ProductVariant
+		if variantID != "" {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ? AND product_id = ?", variantID, productID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return productRepo.ErrVariantNotFound
+				}
+				return err
+			}
+		} else {
+			var variants int64
+			if err := tx.Model(&prodEntities.ProductVariant{}).Where("product_id = ?", productID).Count(&variants).Error; err != nil {
+				return err
+			}
+			if variants > 0 {
+				return ErrVariantRequired
+			}
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("warehouse_id = ? AND product_id = ? AND variant_id = ?", warehouseID, productID, variantID).
+			First(&level).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		delta := quantity - level.Quantity
+
+		if prod.Stock+delta < 0 {
+			return productRepo.ErrInsufficientStock
+		}
+		res = StockResult{
+			ProductBefore: prod.Stock,
+			ProductAfter:  prod.Stock + delta,
+			VariantBefore: variant.Stock,
+			VariantAfter:  variant.Stock + delta,
+		}
+		prod.Stock += delta
+		if err := concurrency.Update(tx, "product", prod.ID, &prod, &prod.Version); err != nil {
+			return err
+		}
+		if variantID != "" {
+			variant.Stock += delta
+			if err := tx.Save(&variant).Error; err != nil {
+				return err
+			}
+		}
+
+		level.WarehouseID = warehouseID
+		level.ProductID = productID
+		level.VariantID = variantID
+		level.Quantity = quantity
+		if err := tx.Save(&level).Error; err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, productID, variantID, warehouseID, delta, e); err != nil {
+			return err
+		}
+		change := StockChange{ProductID: productID, VariantID: variantID, WarehouseID: warehouseID, Delta: delta}
+		if err := enqueueStockEvents(tx, change, &res); err != nil {
+			return err
+		}
+		return productRepo.TrackLowStock(tx, &prod)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &level, &res, nil
+}
+
+// HasStockLevels reports whether one product or variant is stocked in any
+// warehouse.
+func HasStockLevels(tx *gorm.DB, productID, variantID string) (bool, error) {
+	var n int64
+	err := tx.Model(&entities.StockLevel{}).
+		Where("product_id = ? AND variant_id = ?", productID, variantID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// LockStockLevels locks every warehouse level of one product or variant, in
+// warehouse order, inside the caller's transaction.
+func LockStockLevels(tx *gorm.DB, productID, variantID string) ([]entities.StockLevel, error) {
+	var levels []entities.StockLevel
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Warehouse").
+		Where("product_id = ? AND variant_id = ?", productID, variantID).
+		Order("warehouse_id").Find(&levels).Error
+	return levels, err
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"ecommerce-app/domain/inventory/models/request"
+	"ecommerce-app/domain/inventory/models/response"
+	"ecommerce-app/domain/inventory/repositories"
+	"ecommerce-app/shared/pagination"
+)
+
+const defaultPageSize = 50
+
+type MovementUsecase struct {
+	repo repositories.MovementRepository
+}
+
+func NewMovementUsecase(repo repositories.MovementRepository) *MovementUsecase {
+	return &MovementUsecase{repo}
+}
+
+func (uc *MovementUsecase) GetMovements(productID string, req *request.ListMovementsRequest) (*response.MovementListResponse, error) {
+	limit, err := pagination.Limit(req.Limit, defaultPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var before *repositories.MovementCursor
+	if req.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+		if err != nil {
+			return nil, pagination.ErrInvalidCursor
+		}
+		before = &repositories.MovementCursor{}
+		if err := json.Unmarshal(b, before); err != nil || before.ID == "" {
+			return nil, pagination.ErrInvalidCursor
+		}
+	}
+
+	movements, err := uc.repo.FindByProduct(productID, limit+1, before)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &response.MovementListResponse{Items: make([]response.MovementResponse, 0, limit)}
+	if len(movements) > limit {
+		movements = movements[:limit]
+		last := movements[limit-1]
+		b, _ := json.Marshal(repositories.MovementCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(b)
+	}
+	for _, m := range movements {
+		res.Items = append(res.Items, response.MovementResponse{
+			ID: m.ID, ProductID: m.ProductID, VariantID: m.VariantID, WarehouseID: m.WarehouseID,
+			Delta: m.Delta, Reason: m.Reason, ReferenceID: m.ReferenceID, Actor: m.Actor, Note: m.Note,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return res, nil
+}
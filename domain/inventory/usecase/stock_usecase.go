@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	"ecommerce-app/domain/inventory/models/request"
+	"ecommerce-app/domain/inventory/models/response"
+	"ecommerce-app/domain/inventory/repositories"
+	"ecommerce-app/shared/cache"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidAdjustment = errors.New("give either a non-zero quantity or a counted quantity")
+var ErrInvalidReason = errors.New("reason must be one of damage, loss, found, cycle_count")
+var ErrAdjustmentDirection = errors.New("damage and loss can only remove stock and found can only add it")
+var ErrWarehouseRequired = errors.New("item is stocked in warehouses; warehouse_id is required")
+
+type StockUsecase struct {
+	db    *gorm.DB
+	cache *cache.ProductCache
+}
+
+func NewStockUsecase(db *gorm.DB, cache *cache.ProductCache) *StockUsecase {
+	return &StockUsecase{db, cache}
+}
+
+// Restock adds received units to a product or variant and, when the item was
+// out of stock, announces that it is available again.
+func (uc *StockUsecase) Restock(productID string, req *request.RestockRequest, actor string) (*response.StockChangeResponse, error) {
+	change := repositories.StockChange{
+		ProductID:   productID,
+		VariantID:   req.VariantID,
+		WarehouseID: req.WarehouseID,
+		Delta:       req.Quantity,
+	}
+
+	var result *repositories.StockResult
+	err := uc.db.Transaction(func(tx *gorm.DB) error {
+		if err := requireWarehouse(tx, change); err != nil {
+			return err
+		}
+		var err error
+		result, err = repositories.ApplyStockChange(tx, change,
+			ledger.Entry{Reason: entities.MovementRestock, Actor: actor, Note: req.Note})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uc.afterStockChange()
+	return toStockChangeResponse(change, result, entities.MovementRestock), nil
+}
+
+// Adjust records a stock correction by staff: a signed quantity, or a counted
+// quantity that the stock is set to. The product, variant and warehouse rows
+// are locked the way the inventory worker locks them before the count is
+// compared, and nothing may go below zero.
+func (uc *StockUsecase) Adjust(productID string, req *request.AdjustStockRequest, actor string) (*response.StockChangeResponse, error) {
+	if (req.Quantity == nil) == (req.Counted == nil) || (req.Quantity != nil && *req.Quantity == 0) {
+		return nil, ErrInvalidAdjustment
+	}
+	if !entities.IsAdjustmentReason(req.Reason) {
+		return nil, ErrInvalidReason
+	}
+	change := repositories.StockChange{
+		ProductID:   productID,
+		VariantID:   req.VariantID,
+		WarehouseID: req.WarehouseID,
+	}
+
+	var result *repositories.StockResult
+	err := uc.db.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.LockItems(tx, []repositories.StockItem{{ProductID: productID, VariantID: req.VariantID}}); err != nil {
+			return err
+		}
+		if err := requireWarehouse(tx, change); err != nil {
+			return err
+		}
+		if req.Counted != nil {
+			delta, err := repositories.CountedDelta(tx, change, *req.Counted)
+			if err != nil {
+				return err
+			}
+			change.Delta = delta
+		} else {
+			change.Delta = *req.Quantity
+		}
+		if (change.Delta > 0 && (req.Reason == entities.MovementDamage || req.Reason == entities.MovementLoss)) ||
+			(change.Delta < 0 && req.Reason == entities.MovementFound) {
+			return ErrAdjustmentDirection
+		}
+		var err error
+		result, err = repositories.ApplyStockChange(tx, change,
+			ledger.Entry{Reason: req.Reason, Actor: actor, Note: req.Note})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uc.afterStockChange()
+	return toStockChangeResponse(change, result, req.Reason), nil
+}
+
+// requireWarehouse refuses a change that names no warehouse to an item that
+// is stocked in warehouses: it would move the total without any level, and
+// the two would drift apart.
+func requireWarehouse(tx *gorm.DB, c repositories.StockChange) error {
+	if c.WarehouseID != "" {
+		return nil
+	}
+	stocked, err := repositories.HasStockLevels(tx, c.ProductID, c.VariantID)
+	if err != nil {
+		return err
+	}
+	if stocked {
+		return ErrWarehouseRequired
+	}
+	return nil
+}
+
+// afterStockChange runs the side effects of a committed stock change. Its
+// events were queued in the outbox with the change itself.
+func (uc *StockUsecase) afterStockChange() {
+	if err := uc.cache.Invalidate(context.Background()); err != nil {
+		log.Printf("inventory: cache invalidation failed: %v", err)
+	}
+}
+
+func toStockChangeResponse(c repositories.StockChange, r *repositories.StockResult, reason string) *response.StockChangeResponse {
+	res := &response.StockChangeResponse{
+		ProductID:    c.ProductID,
+		VariantID:    c.VariantID,
+		WarehouseID:  c.WarehouseID,
+		Reason:       reason,
+		Delta:        c.Delta,
+		ProductStock: r.ProductAfter,
+	}
+	if c.VariantID != "" {
+		res.VariantStock = &r.VariantAfter
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	"ecommerce-app/domain/inventory/models/request"
+	"ecommerce-app/domain/inventory/models/response"
+	"ecommerce-app/domain/inventory/repositories"
+	"ecommerce-app/shared/cache"
+)
+
+var ErrInvalidWarehouseCode = errors.New("warehouse code is required")
+
+type WarehouseUsecase struct {
+	repo  repositories.WarehouseRepository
+	cache *cache.ProductCache
+}
+
+func NewWarehouseUsecase(repo repositories.WarehouseRepository, cache *cache.ProductCache) *WarehouseUsecase {
+	return &WarehouseUsecase{repo, cache}
+}
+
+func (uc *WarehouseUsecase) CreateWarehouse(req *request.CreateWarehouseRequest) (*response.WarehouseResponse, error) {
+	w := &entities.Warehouse{
+		Code:      strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:      strings.TrimSpace(req.Name),
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Priority:  req.Priority,
+	}
+	if w.Code == "" {
+		return nil, ErrInvalidWarehouseCode
+	}
+
+	if err := uc.repo.Create(w); err != nil {
+		return nil, err
+	}
+
+	res := toWarehouseResponse(w)
+	return &res, nil
+}
+
+func (uc *WarehouseUsecase) GetWarehouses() ([]response.WarehouseResponse, error) {
+	warehouses, err := uc.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]response.WarehouseResponse, 0, len(warehouses))
+	for i := range warehouses {
+		res = append(res, toWarehouseResponse(&warehouses[i]))
+	}
+	return res, nil
+}
+
+func (uc *WarehouseUsecase) GetStockLevels(productID string) ([]response.StockLevelResponse, error) {
+	levels, err := uc.repo.FindStockLevels(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]response.StockLevelResponse, 0, len(levels))
+	for i := range levels {
+		res = append(res, toStockLevelResponse(&levels[i]))
+	}
+	return res, nil
+}
+
+func (uc *WarehouseUsecase) SetStockLevel(warehouseID string, req *request.SetStockLevelRequest, actor string) (*response.StockLevelResponse, error) {
+	level, _, err := uc.repo.SetStockLevel(warehouseID, req.ProductID, req.VariantID, req.Quantity,
+		ledger.Entry{Reason: entities.MovementAdjustment, Actor: actor, Note: "warehouse stock level set"})
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.cache.Invalidate(context.Background()); err != nil {
+		log.Printf("inventory: cache invalidation failed: %v", err)
+	}
+
+	res := toStockLevelResponse(level)
+	return &res, nil
+}
+
+func toWarehouseResponse(w *entities.Warehouse) response.WarehouseResponse {
+	return response.WarehouseResponse{
+		ID: w.ID, Code: w.Code, Name: w.Name, Latitude: w.Latitude, Longitude: w.Longitude,
+		Priority: w.Priority, CreatedAt: w.CreatedAt,
+	}
+}
+
+func toStockLevelResponse(l *entities.StockLevel) response.StockLevelResponse {
+	return response.StockLevelResponse{
+		WarehouseID: l.WarehouseID, ProductID: l.ProductID, VariantID: l.VariantID,
+		Quantity: l.Quantity, UpdatedAt: l.UpdatedAt,
+	}
+}
@@ -4,19 +4,70 @@ import (
 	"time"
 )
 
+// Fulfilment policies decide what happens to an order line the inventory
+// worker cannot fully cover.
+const (
+	// FulfilmentCancelAll cancels the whole order.
+	FulfilmentCancelAll = "cancel_all"
+	// FulfilmentPartial cancels the line and confirms the rest.
+	FulfilmentPartial = "partial"
+	// FulfilmentBackorder ships what is available and fills the remainder
+	// when the item is restocked.
+	FulfilmentBackorder = "backorder"
+)
+
+func IsValidFulfilmentPolicy(p string) bool {
+	switch p {
+	case FulfilmentCancelAll, FulfilmentPartial, FulfilmentBackorder:
+		return true
+	}
+	return false
+}
+
+const (
+	ItemPending     = "PENDING"
+	ItemAllocated   = "ALLOCATED"
+	ItemBackordered = "BACKORDERED"
+	ItemCancelled   = "CANCELLED"
+)
+
+// OrderItemAllocation records how many units of an order line a warehouse
+// ships. A line split across warehouses has one allocation per warehouse.
+type OrderItemAllocation struct {
+	ID          string `gorm:"primaryKey;size:36"`
+	OrderItemID string `gorm:"index;size:36;not null"`
+	WarehouseID string `gorm:"index;size:36;not null"`
+	Quantity    int    `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+// OrderItem is one order line. FulfilmentPolicy is resolved when the order is
+// placed (the product's policy, else the order's) and FulfilledQuantity counts
+// the units taken out of stock so far.
 type OrderItem struct {
-	ID        string `gorm:"primaryKey;size:36"`
-	OrderID   string `gorm:"index;size:36"`
-	ProductID string `gorm:"size:36;not null"`
-	Quantity  int    `gorm:"not null"`
+	ID                string                `gorm:"primaryKey;size:36"`
+	OrderID           string                `gorm:"index;size:36"`
+	ProductID         string                `gorm:"size:36;not null"`
+	VariantID         string                `gorm:"size:36"`
+	Quantity          int                   `gorm:"not null"`
+	UnitPrice         float64               `gorm:"not null;default:0"`
+	FulfilmentPolicy  string                `gorm:"size:20;not null;default:'cancel_all'"`
+	Status            string                `gorm:"size:20;not null;default:'PENDING';index"`
+	FulfilledQuantity int                   `gorm:"not null;default:0"`
+	Allocations       []OrderItemAllocation `gorm:"foreignKey:OrderItemID;constraint:OnDelete:CASCADE"`
 }
 
 type Order struct {
-	ID        string      `gorm:"primaryKey;size:36"`
-	UserID    string      `gorm:"index;size:36;not null"`
-	Status    string      `gorm:"size:50;not null"` 
-	Total     float64     `gorm:"not null;default:0"`
-	Items     []OrderItem `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID                string      `gorm:"primaryKey;size:36"`
+	UserID            string      `gorm:"index;index:idx_orders_user_created,priority:1;size:36;not null"`
+	Status            OrderStatus `gorm:"size:50;not null"`
+	Total             float64     `gorm:"not null;default:0"`
+	FulfilmentPolicy  string      `gorm:"size:20;not null;default:'cancel_all'"`
+	Version           int         `gorm:"not null;default:1"`
+	ShippingLatitude  *float64
+	ShippingLongitude *float64
+	Items             []OrderItem         `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	History           []OrderStatusChange `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	CreatedAt         time.Time           `gorm:"index:idx_orders_user_created,priority:2"`
+	UpdatedAt         time.Time
 }
@@ -0,0 +1,106 @@
+package entities
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OrderStatus is where an order is in its life. The main line runs pending,
+// paid, confirmed, shipped, delivered; orders can be backordered while they
+// wait for stock, cancelled before they ship and refunded after they are
+// cancelled or shipped.
+type OrderStatus string
+
+const (
+	StatusPending     OrderStatus = "PENDING"
+	StatusPaid        OrderStatus = "PAID"
+	StatusBackordered OrderStatus = "BACKORDERED"
+	StatusConfirmed   OrderStatus = "CONFIRMED"
+	StatusShipped     OrderStatus = "SHIPPED"
+	StatusDelivered   OrderStatus = "DELIVERED"
+	StatusCancelled   OrderStatus = "CANCELLED"
+	StatusRefunded    OrderStatus = "REFUNDED"
+)
+
+var ErrInvalidTransition = errors.New("invalid order status transition")
+
+// transitions lists the statuses each status may move to. Pending and paid
+// orders both wait for the inventory worker, which confirms, backorders or
+// cancels them. A refund of an order that has not shipped goes through
+// cancellation first so its stock is restored.
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusPending:     {StatusPaid, StatusConfirmed, StatusBackordered, StatusCancelled},
+	StatusPaid:        {StatusConfirmed, StatusBackordered, StatusCancelled},
+	StatusBackordered: {StatusConfirmed, StatusCancelled},
+	StatusConfirmed:   {StatusShipped, StatusCancelled},
+	StatusShipped:     {StatusDelivered, StatusRefunded},
+	StatusDelivered:   {StatusRefunded},
+	StatusCancelled:   {StatusRefunded},
+}
+
+func IsValidOrderStatus(s OrderStatus) bool {
+	switch s {
+	case StatusPending, StatusPaid, StatusBackordered, StatusConfirmed,
+		StatusShipped, StatusDelivered, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an order may move from s to to.
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AwaitingAllocation reports whether the inventory worker has still to take
+// stock for the order.
+func (s OrderStatus) AwaitingAllocation() bool {
+	return s == StatusPending || s == StatusPaid
+}
+
+// Transition moves o to status to and returns the history row that records
+// it. Moves the transition table does not allow fail with an error wrapping
+// ErrInvalidTransition and leave o unchanged.
+func (o *Order) Transition(to OrderStatus, actor, reason string) (*OrderStatusChange, error) {
+	if !o.Status.CanTransitionTo(to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, o.Status, to)
+	}
+	change := &OrderStatusChange{
+		OrderID:    o.ID,
+		FromStatus: o.Status,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	o.Status = to
+	return change, nil
+}
+
+// OrderStatusChange is one entry in an order's status history. FromStatus is
+// empty for the entry written when the order is placed.
+type OrderStatusChange struct {
+	ID         string      `gorm:"primaryKey;size:36"`
+	OrderID    string      `gorm:"index;size:36;not null"`
+	FromStatus OrderStatus `gorm:"size:50;not null;default:''"`
+	ToStatus   OrderStatus `gorm:"size:50;not null"`
+	Actor      string      `gorm:"size:64"`
+	Reason     string      `gorm:"size:255"`
+	CreatedAt  time.Time   `gorm:"not null"`
+}
+
+func (c *OrderStatusChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.NewString()
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	all := []OrderStatus{
+		StatusPending, StatusPaid, StatusBackordered, StatusConfirmed,
+		StatusShipped, StatusDelivered, StatusCancelled, StatusRefunded,
+	}
+	allowed := map[OrderStatus][]OrderStatus{
+		StatusPending:     {StatusPaid, StatusConfirmed, StatusBackordered, StatusCancelled},
+		StatusPaid:        {StatusConfirmed, StatusBackordered, StatusCancelled},
+		StatusBackordered: {StatusConfirmed, StatusCancelled},
+		StatusConfirmed:   {StatusShipped, StatusCancelled},
+		StatusShipped:     {StatusDelivered, StatusRefunded},
+		StatusDelivered:   {StatusRefunded},
+		StatusCancelled:   {StatusRefunded},
+		StatusRefunded:    nil,
+	}
+
+	for _, from := range all {
+		for _, to := range all {
+			want := false
+			for _, s := range allowed[from] {
+				if s == to {
+					want = true
+				}
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: CanTransitionTo = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestAwaitingAllocation(t *testing.T) {
+	tests := []struct {
+		status OrderStatus
+		want   bool
+	}{
+		{StatusPending, true},
+		{StatusPaid, true},
+		{StatusBackordered, false},
+		{StatusConfirmed, false},
+		{StatusCancelled, false},
+	}
+	for _, tt := range tests {
+		if got := tt.status.AwaitingAllocation(); got != tt.want {
+			t.Errorf("%s.AwaitingAllocation() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestOrderTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    OrderStatus
+		to      OrderStatus
+		wantErr bool
+	}{
+		{"pending to confirmed", StatusPending, StatusConfirmed, false},
+		{"backordered to cancelled", StatusBackordered, StatusCancelled, false},
+		{"shipped to refunded", StatusShipped, StatusRefunded, false},
+		{"shipped to cancelled", StatusShipped, StatusCancelled, true},
+		{"delivered to pending", StatusDelivered, StatusPending, true},
+		{"refunded is final", StatusRefunded, StatusCancelled, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Order{ID: "order-1", Status: tt.from}
+			change, err := o.Transition(tt.to, "tester", "because")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Fatalf("err = %v, want ErrInvalidTransition", err)
+				}
+				if o.Status != tt.from {
+					t.Fatalf("status = %s after a refused move, want %s", o.Status, tt.from)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if o.Status != tt.to {
+				t.Fatalf("status = %s, want %s", o.Status, tt.to)
+			}
+			if change.OrderID != o.ID || change.FromStatus != tt.from || change.ToStatus != tt.to ||
+				change.Actor != "tester" || change.Reason != "because" {
+				t.Fatalf("change = %+v, does not record the move", change)
+			}
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	orderEntities "ecommerce-app/domain/orders/entities"
 	orderModelsRequest "ecommerce-app/domain/orders/models/request"
+	orderRepositories "ecommerce-app/domain/orders/repositories"
 	"ecommerce-app/domain/orders/usecase"
+	productRepositories "ecommerce-app/domain/products/repositories"
+	"ecommerce-app/shared/concurrency"
 	"ecommerce-app/shared/middleware"
+	"ecommerce-app/shared/pagination"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,10 +37,18 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 	id, err := h.uc.CreateOrder(c.Request.Context(), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err {
+		case usecase.ErrEmptyItems, usecase.ErrVariantRequired, usecase.ErrVariantMismatch,
+			productRepositories.ErrProductNotFound, productRepositories.ErrVariantNotFound:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case productRepositories.ErrInsufficientStock:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
-	c.JSON(http.StatusAccepted, gin.H{"order_id": id, "status": "PENDING"})
+	c.JSON(http.StatusAccepted, gin.H{"order_id": id, "status": orderEntities.StatusPending})
 }
 
 func (h *OrderHandler) GetOrder(c *gin.Context) {
@@ -51,3 +65,74 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, resp)
 }
+
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	var req orderModelsRequest.ListOrdersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	resp, err := h.uc.ListOrders(c.Request.Context(), userID, &req)
+	if err != nil {
+		switch err {
+		case pagination.ErrInvalidLimit, pagination.ErrInvalidCursor, usecase.ErrInvalidDateRange:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	resp, err := h.uc.CancelOrder(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		if concurrency.IsConflict(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		switch err {
+		case orderRepositories.ErrOrderNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case usecase.ErrOrderNotCancellable:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OrderHandler) UpdateStatus(c *gin.Context) {
+	var req orderModelsRequest.UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor, _ := middleware.GetUserID(c)
+	resp, err := h.uc.UpdateStatus(c.Request.Context(), c.Param("id"), &req, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, orderEntities.ErrInvalidTransition), concurrency.IsConflict(err):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err == orderRepositories.ErrOrderNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -2,9 +2,35 @@ package request
 
 type OrderItemRequest struct {
 	ProductID string `json:"product_id" binding:"required,uuid"`
+	VariantID string `json:"variant_id,omitempty" binding:"omitempty,uuid"`
 	Quantity  int    `json:"quantity" binding:"required,min=1"`
 }
 
+type ShippingLocation struct {
+	Latitude  float64 `json:"latitude" binding:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" binding:"min=-180,max=180"`
+}
+
 type CreateOrderRequest struct {
-	Items []OrderItemRequest `json:"items" binding:"required,dive,required"`
-}
\ No newline at end of file
+	Items            []OrderItemRequest `json:"items" binding:"required,dive,required"`
+	ShippingLocation *ShippingLocation  `json:"shipping_location,omitempty"`
+	FulfilmentPolicy string             `json:"fulfilment_policy,omitempty" binding:"omitempty,oneof=cancel_all partial backorder"`
+}
+
+// ListOrdersRequest filters a customer's order history. From and To take an
+// RFC 3339 timestamp or a YYYY-MM-DD date; To is exclusive.
+type ListOrdersRequest struct {
+	Status string `form:"status" binding:"omitempty,oneof=PENDING PAID BACKORDERED CONFIRMED SHIPPED DELIVERED CANCELLED REFUNDED"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+// UpdateOrderStatusRequest is a staff move along the fulfilment line.
+// Version, when sent, must match the order's current version.
+type UpdateOrderStatusRequest struct {
+	Status  string `json:"status" binding:"required,oneof=PAID SHIPPED DELIVERED REFUNDED"`
+	Reason  string `json:"reason,omitempty" binding:"max=255"`
+	Version *int   `json:"version,omitempty"`
+}
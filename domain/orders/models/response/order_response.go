@@ -2,17 +2,44 @@ package response
 
 import "time"
 
+type AllocationResponse struct {
+	WarehouseID string `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+}
+
+type StatusChangeResponse struct {
+	From   string    `json:"from,omitempty"`
+	To     string    `json:"to"`
+	Actor  string    `json:"actor,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
 type OrderItemResponse struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	ProductID         string               `json:"product_id"`
+	VariantID         string               `json:"variant_id,omitempty"`
+	Quantity          int                  `json:"quantity"`
+	UnitPrice         float64              `json:"unit_price"`
+	Status            string               `json:"status"`
+	FulfilledQuantity int                  `json:"fulfilled_quantity"`
+	FulfilmentPolicy  string               `json:"fulfilment_policy"`
+	Allocations       []AllocationResponse `json:"allocations,omitempty"`
 }
 
 type OrderResponse struct {
-	ID        string               `json:"id"`
-	UserID    string               `json:"user_id"`
-	Status    string               `json:"status"`
-	Total     float64              `json:"total"`
-	Items     []OrderItemResponse  `json:"items"`
-	CreatedAt time.Time            `json:"created_at"`
-	UpdatedAt time.Time            `json:"updated_at"`
-}
\ No newline at end of file
+	ID               string                 `json:"id"`
+	UserID           string                 `json:"user_id"`
+	Status           string                 `json:"status"`
+	Total            float64                `json:"total"`
+	FulfilmentPolicy string                 `json:"fulfilment_policy"`
+	Version          int                    `json:"version"`
+	Items            []OrderItemResponse    `json:"items"`
+	Timeline         []StatusChangeResponse `json:"timeline,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+}
+
+type OrderListResponse struct {
+	Items      []OrderResponse `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
@@ -2,17 +2,39 @@ package repositories
 
 import (
 	"ecommerce-app/domain/orders/entities"
+	"ecommerce-app/shared/concurrency"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var ErrOrderNotFound = errors.New("order not found")
 
+// OrderCursor is the last order of a page; the next page holds older orders.
+type OrderCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// OrderFilter narrows a user's orders. Zero fields do not filter; From is
+// inclusive and To exclusive.
+type OrderFilter struct {
+	UserID string
+	Status entities.OrderStatus
+	From   *time.Time
+	To     *time.Time
+	Before *OrderCursor
+	Limit  int
+}
+
 type OrderRepository interface {
 	Create(order *entities.Order) error
 	FindByID(id string) (*entities.Order, error)
+	FindByUser(f OrderFilter) ([]entities.Order, error)
 	Update(order *entities.Order) error
+	WithTx(tx *gorm.DB) OrderRepository
 }
 
 type GormOrderRepo struct {
@@ -29,7 +51,9 @@ func (r *GormOrderRepo) Create(order *entities.Order) error {
 
 func (r *GormOrderRepo) FindByID(id string) (*entities.Order, error) {
 	var order entities.Order
-	err := r.db.Preload("Items").First(&order, "id = ?", id).Error
+	err := r.db.Preload("Items.Allocations").
+		Preload("History", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		First(&order, "id = ?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrOrderNotFound
@@ -39,6 +63,64 @@ func (r *GormOrderRepo) FindByID(id string) (*entities.Order, error) {
 	return &order, nil
 }
 
+// FindByUser returns the user's orders with their lines, newest first.
+func (r *GormOrderRepo) FindByUser(f OrderFilter) ([]entities.Order, error) {
+	var orders []entities.Order
+	q := r.db.Preload("Items.Allocations").Where("user_id = ?", f.UserID)
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.From != nil {
+		q = q.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		q = q.Where("created_at < ?", *f.To)
+	}
+	if f.Before != nil {
+		q = q.Where("(created_at, id) < (?, ?)", f.Before.CreatedAt, f.Before.ID)
+	}
+	err := q.Order("created_at DESC, id DESC").Limit(f.Limit).Find(&orders).Error
+	return orders, err
+}
+
+// Update saves the order row, not its items, if nobody else has changed it
+// since it was read, and returns a *concurrency.ConflictError otherwise.
 func (r *GormOrderRepo) Update(order *entities.Order) error {
-	return r.db.Save(order).Error
+	return concurrency.Update(r.db, "order", order.ID, order, &order.Version)
+}
+
+// LockByID locks the order row FOR UPDATE inside tx and then reads the order
+// with its items, allocations and history, so nothing the caller decides on
+// can change underneath it before tx ends.
+func LockByID(tx *gorm.DB, id string) (*entities.Order, error) {
+	var locked entities.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return (&GormOrderRepo{tx}).FindByID(id)
+}
+
+// Transition moves order to status to inside tx and appends the move to the
+// order's history. The caller saves the order row itself.
+func Transition(tx *gorm.DB, order *entities.Order, to entities.OrderStatus, actor, reason string) error {
+	change, err := order.Transition(to, actor, reason)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(change).Error; err != nil {
+		order.Status = change.FromStatus
+		return err
+	}
+	order.History = append(order.History, *change)
+	return nil
+}
+
+// WithTx returns a repository bound to tx, so order writes can share a
+// transaction with other tables.
+func (r *GormOrderRepo) WithTx(tx *gorm.DB) OrderRepository {
+	return &GormOrderRepo{tx}
 }
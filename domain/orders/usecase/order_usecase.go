@@ -2,159 +2,435 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"log"
 	"os"
+	"strconv"
 	"time"
 
 	orderEntities "ecommerce-app/domain/orders/entities"
 	orderModelsRequest "ecommerce-app/domain/orders/models/request"
 	orderModelsResponse "ecommerce-app/domain/orders/models/response"
+	productEntities "ecommerce-app/domain/products/entities"
 	productRepo "ecommerce-app/domain/products/repositories"
 	orderRepo "ecommerce-app/domain/orders/repositories"
-	"ecommerce-app/config"
+	inventoryEntities "ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	inventoryRepo "ecommerce-app/domain/inventory/repositories"
+	outboxRepo "ecommerce-app/domain/outbox/repositories"
+	"ecommerce-app/events"
+	"ecommerce-app/shared/cache"
+	"ecommerce-app/shared/concurrency"
+	"ecommerce-app/shared/pagination"
+	"ecommerce-app/shared/retry"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
 var ErrEmptyItems = errors.New("order items cannot be empty")
+var ErrVariantRequired = errors.New("product has variants; variant_id is required")
+var ErrVariantMismatch = errors.New("variant does not belong to product")
+var ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+var ErrInvalidDateRange = errors.New("from and to must be RFC 3339 timestamps or YYYY-MM-DD dates, with from before to")
+
+const defaultPageSize = 20
 
 type OrderUsecase struct {
+	db          *gorm.DB
 	orderRepo   orderRepo.OrderRepository
 	productRepo productRepo.ProductRepository
 	redis       *redis.Client
+	cache       *cache.ProductCache
 }
 
-func NewOrderUsecase(or orderRepo.OrderRepository, pr productRepo.ProductRepository, r *redis.Client) *OrderUsecase {
+func NewOrderUsecase(db *gorm.DB, or orderRepo.OrderRepository, pr productRepo.ProductRepository, r *redis.Client) *OrderUsecase {
 	return &OrderUsecase{
+		db:          db,
 		orderRepo:   or,
 		productRepo: pr,
 		redis:       r,
+		cache:       cache.NewProductCache(r),
 	}
 }
 
-type OrderPlacedPayload struct {
-	OrderID string `json:"order_id"`
-	UserID  string `json:"user_id"`
-	Items   []struct {
-		ProductID string `json:"product_id"`
-		Quantity  int    `json:"quantity"`
-	} `json:"items"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
 func (uc *OrderUsecase) CreateOrder(ctx context.Context, userID string, req *orderModelsRequest.CreateOrderRequest) (string, error) {
 	if len(req.Items) == 0 {
 		return "", ErrEmptyItems
 	}
 
-	for _, it := range req.Items {
-		if _, err := uc.productRepo.FindByID(it.ProductID); err != nil {
-			return "", err
-		}
+	policy := req.FulfilmentPolicy
+	if policy == "" {
+		policy = orderEntities.FulfilmentCancelAll
 	}
 
-
 	orderID := uuid.NewString()
 	order := &orderEntities.Order{
-		ID:     orderID,
-		UserID: userID,
-		Status: "PENDING",
-		Items:  make([]orderEntities.OrderItem, 0, len(req.Items)),
+		ID:               orderID,
+		UserID:           userID,
+		Status:           orderEntities.StatusPending,
+		History: []orderEntities.OrderStatusChange{{
+			ToStatus: orderEntities.StatusPending, Actor: userID, Reason: "placed", CreatedAt: time.Now(),
+		}},
+		FulfilmentPolicy: policy,
+		Items:            make([]orderEntities.OrderItem, 0, len(req.Items)),
+	}
+	if req.ShippingLocation != nil {
+		order.ShippingLatitude = &req.ShippingLocation.Latitude
+		order.ShippingLongitude = &req.ShippingLocation.Longitude
 	}
 
 	var total float64
 	for _, it := range req.Items {
-		p, err := uc.productRepo.FindByID(it.ProductID)
+		price, p, err := uc.unitPrice(it)
 		if err != nil {
 			return "", err
 		}
-		total += p.Price * float64(it.Quantity)
+		itemPolicy := policy
+		if orderEntities.IsValidFulfilmentPolicy(p.FulfilmentPolicy) {
+			itemPolicy = p.FulfilmentPolicy
+		}
+		total += price * float64(it.Quantity)
 		order.Items = append(order.Items, orderEntities.OrderItem{
-			ID:        uuid.NewString(),
-			OrderID:   orderID,
+			ID:               uuid.NewString(),
+			OrderID:          orderID,
+			ProductID:        it.ProductID,
+			VariantID:        it.VariantID,
+			Quantity:         it.Quantity,
+			UnitPrice:        price,
+			FulfilmentPolicy: itemPolicy,
+			Status:           orderEntities.ItemPending,
+		})
+	}
+	order.Total = total
+
+	lines := make([]inventoryRepo.ReservationLine, 0, len(order.Items))
+	for _, it := range order.Items {
+		lines = append(lines, inventoryRepo.ReservationLine{
+			ProductID:  it.ProductID,
+			VariantID:  it.VariantID,
+			Quantity:   it.Quantity,
+			AllowShort: it.FulfilmentPolicy != orderEntities.FulfilmentCancelAll,
+		})
+	}
+	routingKey := os.Getenv("RABBITMQ_ROUTING_KEY")
+	if routingKey == "" {
+		routingKey = "order.placed"
+	}
+	queue := os.Getenv("RABBITMQ_QUEUE")
+	if queue == "" {
+		queue = "order_placed_queue"
+	}
+	payload := events.OrderPlacedPayload{
+		OrderID:   orderID,
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, it := range req.Items {
+		payload.Items = append(payload.Items, events.OrderItemPayload{
 			ProductID: it.ProductID,
+			VariantID: it.VariantID,
 			Quantity:  it.Quantity,
 		})
 	}
-	order.Total = total
 
-	if err := uc.orderRepo.Create(order); err != nil {
+	// The placed event is written to the outbox with the order, so the
+	// inventory worker hears about every order that commits and no other.
+	err := retry.Do(ctx, retry.PolicyFromEnv("ORDER"), func() error {
+		return uc.db.Transaction(func(tx *gorm.DB) error {
+			if err := inventoryRepo.Reserve(tx, orderID, lines, time.Now().Add(reservationTTL())); err != nil {
+				return err
+			}
+			if err := uc.orderRepo.WithTx(tx).Create(order); err != nil {
+				return err
+			}
+			return outboxRepo.Enqueue(tx, events.Exchange(), routingKey, queue, payload)
+		})
+	})
+	if err != nil {
 		return "", err
 	}
 
-	go func() {
-		ch, err := config.NewChannel()
-		if err != nil {
+	return orderID, nil
+}
 
-			return
-		}
-		defer ch.Close()
+func (uc *OrderUsecase) GetOrder(ctx context.Context, userID, orderID string) (*orderModelsResponse.OrderResponse, error) {
+	order, err := uc.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, err
+	}
 
-		exchange := os.Getenv("RABBITMQ_EXCHANGE")
-		if exchange == "" {
-			exchange = "orders_direct"
-		}
-		routingKey := os.Getenv("RABBITMQ_ROUTING_KEY")
-		if routingKey == "" {
-			routingKey = "order.placed"
-		}
-		queue := os.Getenv("RABBITMQ_QUEUE")
-		if queue == "" {
-			queue = "order_placed_queue"
-		}
+	if order.UserID != userID {
+		return nil, errors.New("not authorized to view this order")
+	}
+	return toOrderResponse(order), nil
+}
 
-		if err := config.EnsureDirectExchange(ch, exchange); err != nil {
-			return
-		}
-		_, _ = config.DeclareQuorumQueue(ch, queue, exchange, routingKey)
+// CancelOrder lets the owner cancel an order that has not shipped. Holds are
+// released, and units the inventory worker already took go back to the
+// warehouses they came from as return movements. The order row is locked
+// and its lines reread before any stock row, as fillBackorder does, so units
+// a concurrent backorder fill allocated are returned too.
+func (uc *OrderUsecase) CancelOrder(ctx context.Context, userID, orderID string) (*orderModelsResponse.OrderResponse, error) {
+	var order *orderEntities.Order
+	var changes []inventoryRepo.StockChange
+	err := retry.Do(ctx, retry.PolicyFromEnv("ORDER"), func() error {
+		changes = nil
+		return uc.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if order, err = orderRepo.LockByID(tx, orderID); err != nil {
+				return err
+			}
+			if order.UserID != userID {
+				return orderRepo.ErrOrderNotFound
+			}
+			if !order.Status.CanTransitionTo(orderEntities.StatusCancelled) {
+				return ErrOrderNotCancellable
+			}
+
+			items := make([]inventoryRepo.StockItem, 0, len(order.Items))
+			for _, it := range order.Items {
+				items = append(items, inventoryRepo.StockItem{ProductID: it.ProductID, VariantID: it.VariantID})
+			}
+			if err := inventoryRepo.LockItems(tx, items); err != nil {
+				return err
+			}
+			entry := ledger.Entry{Reason: inventoryEntities.MovementReturn, ReferenceID: order.ID, Actor: userID, Note: "order cancelled"}
+			for i := range order.Items {
+				it := &order.Items[i]
+				// Lines from before fulfilment was tracked per line were
+				// taken in full when the order was confirmed.
+				if it.Status == orderEntities.ItemPending && order.Status == orderEntities.StatusConfirmed {
+					it.FulfilledQuantity = it.Quantity
+				}
+				for _, c := range takenStock(it) {
+					if _, err := inventoryRepo.ApplyStockChange(tx, c, entry); err != nil {
+						return err
+					}
+					changes = append(changes, c)
+				}
+				it.Status = orderEntities.ItemCancelled
+				if err := tx.Model(it).Updates(map[string]interface{}{
+					"status": it.Status, "fulfilled_quantity": it.FulfilledQuantity,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			if err := inventoryRepo.ReleaseReservations(tx, order.ID); err != nil {
+				return err
+			}
+			if err := orderRepo.Transition(tx, order, orderEntities.StatusCancelled, userID, "cancelled by customer"); err != nil {
+				return err
+			}
+			if err := uc.orderRepo.WithTx(tx).Update(order); err != nil {
+				return err
+			}
+			cancelled := events.OrderCancelledPayload{OrderID: order.ID, UserID: order.UserID, CreatedAt: time.Now().UTC()}
+			for _, c := range changes {
+				cancelled.Restored = append(cancelled.Restored, events.OrderItemPayload{
+					ProductID: c.ProductID, VariantID: c.VariantID, Quantity: c.Delta,
+				})
+			}
+			return outboxRepo.Enqueue(tx, events.Exchange(), events.OrderCancelledRoutingKey(), events.OrderCancelledQueue(), cancelled)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		payload := OrderPlacedPayload{
-			OrderID: orderID,
-			UserID:  userID,
-			CreatedAt: time.Now().UTC(),
+	if len(changes) > 0 {
+		if err := uc.cache.Invalidate(context.Background()); err != nil {
+			log.Printf("orders: cache invalidation failed: %v", err)
 		}
-		for _, it := range req.Items {
-			payload.Items = append(payload.Items, struct {
-				ProductID string `json:"product_id"`
-				Quantity  int    `json:"quantity"`
-			}{
-				ProductID: it.ProductID,
-				Quantity:  it.Quantity,
-			})
+	}
+	return toOrderResponse(order), nil
+}
+
+// UpdateStatus moves an order along the fulfilment line on behalf of staff:
+// marking it paid, shipped, delivered or refunded. Cancellation goes through
+// CancelOrder so stock is restored.
+func (uc *OrderUsecase) UpdateStatus(ctx context.Context, orderID string, req *orderModelsRequest.UpdateOrderStatusRequest, actor string) (*orderModelsResponse.OrderResponse, error) {
+	order, err := uc.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := concurrency.Check("order", order.ID, req.Version, order.Version); err != nil {
+		return nil, err
+	}
+	err = uc.db.Transaction(func(tx *gorm.DB) error {
+		if err := orderRepo.Transition(tx, order, orderEntities.OrderStatus(req.Status), actor, req.Reason); err != nil {
+			return err
 		}
-		b, _ := json.Marshal(payload)
-		_ = config.PublishJSON(ch, exchange, routingKey, b)
-	}()
+		return uc.orderRepo.WithTx(tx).Update(order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOrderResponse(order), nil
+}
 
-	return orderID, nil
+// takenStock returns the changes that put a line's fulfilled units back:
+// one per warehouse it was allocated from, plus any units taken without a
+// warehouse.
+func takenStock(it *orderEntities.OrderItem) []inventoryRepo.StockChange {
+	var changes []inventoryRepo.StockChange
+	remaining := it.FulfilledQuantity
+	for _, a := range it.Allocations {
+		changes = append(changes, inventoryRepo.StockChange{
+			ProductID: it.ProductID, VariantID: it.VariantID, WarehouseID: a.WarehouseID, Delta: a.Quantity,
+		})
+		remaining -= a.Quantity
+	}
+	if remaining > 0 {
+		changes = append(changes, inventoryRepo.StockChange{
+			ProductID: it.ProductID, VariantID: it.VariantID, Delta: remaining,
+		})
+	}
+	return changes
 }
 
-func (uc *OrderUsecase) GetOrder(ctx context.Context, userID, orderID string) (*orderModelsResponse.OrderResponse, error) {
-	order, err := uc.orderRepo.FindByID(orderID)
+// ListOrders pages through the user's orders, newest first.
+func (uc *OrderUsecase) ListOrders(ctx context.Context, userID string, req *orderModelsRequest.ListOrdersRequest) (*orderModelsResponse.OrderListResponse, error) {
+	limit, err := pagination.Limit(req.Limit, defaultPageSize)
 	if err != nil {
 		return nil, err
 	}
 
-	if order.UserID != userID {
-		return nil, errors.New("not authorized to view this order")
+	f := orderRepo.OrderFilter{UserID: userID, Status: orderEntities.OrderStatus(req.Status), Limit: limit + 1}
+	if f.From, err = parseDateParam(req.From); err != nil {
+		return nil, ErrInvalidDateRange
+	}
+	if f.To, err = parseDateParam(req.To); err != nil {
+		return nil, ErrInvalidDateRange
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return nil, ErrInvalidDateRange
+	}
+	if req.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+		if err != nil {
+			return nil, pagination.ErrInvalidCursor
+		}
+		f.Before = &orderRepo.OrderCursor{}
+		if err := json.Unmarshal(b, f.Before); err != nil || f.Before.ID == "" {
+			return nil, pagination.ErrInvalidCursor
+		}
+	}
+
+	orders, err := uc.orderRepo.FindByUser(f)
+	if err != nil {
+		return nil, err
 	}
 
+	res := &orderModelsResponse.OrderListResponse{Items: make([]orderModelsResponse.OrderResponse, 0, limit)}
+	if len(orders) > limit {
+		orders = orders[:limit]
+		last := orders[limit-1]
+		b, _ := json.Marshal(orderRepo.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		res.NextCursor = base64.RawURLEncoding.EncodeToString(b)
+	}
+	for i := range orders {
+		res.Items = append(res.Items, *toOrderResponse(&orders[i]))
+	}
+	return res, nil
+}
+
+// parseDateParam reads an RFC 3339 timestamp or a YYYY-MM-DD date (midnight
+// UTC). An empty string is no bound.
+func parseDateParam(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", s); err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func toOrderResponse(order *orderEntities.Order) *orderModelsResponse.OrderResponse {
 	resp := &orderModelsResponse.OrderResponse{
 		ID:     order.ID,
 		UserID: order.UserID,
-		Status: order.Status,
+		Status: string(order.Status),
 		Total:  order.Total,
+		FulfilmentPolicy: order.FulfilmentPolicy,
+		Version:   order.Version,
 		CreatedAt: order.CreatedAt,
 		UpdatedAt: order.UpdatedAt,
 		Items:  []orderModelsResponse.OrderItemResponse{},
 	}
 	for _, it := range order.Items {
-		resp.Items = append(resp.Items, orderModelsResponse.OrderItemResponse{
-			ProductID: it.ProductID,
-			Quantity:  it.Quantity,
+		item := orderModelsResponse.OrderItemResponse{
+			ProductID:         it.ProductID,
+			VariantID:         it.VariantID,
+			Quantity:          it.Quantity,
+			UnitPrice:         it.UnitPrice,
+			Status:            it.Status,
+			FulfilledQuantity: it.FulfilledQuantity,
+			FulfilmentPolicy:  it.FulfilmentPolicy,
+		}
+		for _, a := range it.Allocations {
+			item.Allocations = append(item.Allocations, orderModelsResponse.AllocationResponse{
+				WarehouseID: a.WarehouseID,
+				Quantity:    a.Quantity,
+			})
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	for _, h := range order.History {
+		resp.Timeline = append(resp.Timeline, orderModelsResponse.StatusChangeResponse{
+			From:   string(h.FromStatus),
+			To:     string(h.ToStatus),
+			Actor:  h.Actor,
+			Reason: h.Reason,
+			At:     h.CreatedAt,
 		})
 	}
-	return resp, nil
+	return resp
+}
+
+func reservationTTL() time.Duration {
+	ttl := 15
+	if v := os.Getenv("RESERVATION_TTL_MINUTES"); v != "" {
+		if parsed, _ := strconv.Atoi(v); parsed > 0 {
+			ttl = parsed
+		}
+	}
+	return time.Duration(ttl) * time.Minute
+}
+
+// unitPrice resolves the price of one order line, honouring a variant's price
+// override, and returns the product it belongs to. Products that have
+// variants can only be ordered by variant.
+func (uc *OrderUsecase) unitPrice(it orderModelsRequest.OrderItemRequest) (float64, *productEntities.Product, error) {
+	p, err := uc.productRepo.FindByID(it.ProductID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if it.VariantID == "" {
+		variants, err := uc.productRepo.FindVariantsByProductID(p.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(variants) > 0 {
+			return 0, nil, ErrVariantRequired
+		}
+		return p.Price, p, nil
+	}
+
+	v, err := uc.productRepo.FindVariantByID(it.VariantID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if v.ProductID != p.ID {
+		return 0, nil, ErrVariantMismatch
+	}
+	return v.EffectivePrice(p.Price), p, nil
 }
@@ -0,0 +1,30 @@
+package entities
+
+import "time"
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	// OutboxFailed messages ran out of attempts and are no longer published;
+	// setting one back to pending retries it.
+	OutboxFailed = "failed"
+)
+
+// OutboxMessage is an event waiting to be published. It is written in the
+// same transaction as the change it announces, so the event exists exactly
+// when the change commits, and the outbox relay publishes it afterwards.
+type OutboxMessage struct {
+	ID         string `gorm:"primaryKey;size:36"`
+	Exchange   string `gorm:"size:255;not null"`
+	RoutingKey string `gorm:"size:255;not null"`
+	// Queue, when set, is declared and bound before publishing so the event
+	// is kept even if its consumer has never run.
+	Queue         string    `gorm:"size:255;not null;default:''"`
+	Payload       []byte    `gorm:"not null"`
+	Status        string    `gorm:"size:20;not null;default:'pending';index:idx_outbox_messages_due,priority:1"`
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_messages_due,priority:2"`
+	LastError     string    `gorm:"size:500"`
+	CreatedAt     time.Time
+	SentAt        *time.Time `gorm:"index"`
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"encoding/json"
+	"time"
+
+	"ecommerce-app/domain/outbox/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Enqueue stores v as JSON for the relay to publish to exchange under
+// routingKey, inside the caller's transaction. A non-empty queue is declared
+// and bound to the routing key before publishing.
+func Enqueue(tx *gorm.DB, exchange, routingKey, queue string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return tx.Create(&entities.OutboxMessage{
+		ID:            uuid.NewString(),
+		Exchange:      exchange,
+		RoutingKey:    routingKey,
+		Queue:         queue,
+		Payload:       body,
+		Status:        entities.OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+// ClaimDue takes up to limit pending messages that are due by now, oldest
+// first, and pushes their next attempt back by lease, so other relays leave
+// them alone while they are published outside tx. Rows another relay has
+// locked are skipped. A message whose relay dies before marking it is due
+// again once the lease runs out.
+func ClaimDue(tx *gorm.DB, now time.Time, limit int, lease time.Duration) ([]entities.OutboxMessage, error) {
+	var msgs []entities.OutboxMessage
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", entities.OutboxPending, now).
+		Order("created_at, id").Limit(limit).Find(&msgs).Error
+	if err != nil || len(msgs) == 0 {
+		return msgs, err
+	}
+	ids := make([]string, len(msgs))
+	for i := range msgs {
+		ids[i] = msgs[i].ID
+	}
+	err = tx.Model(&entities.OutboxMessage{}).Where("id IN ?", ids).
+		Update("next_attempt_at", now.Add(lease)).Error
+	return msgs, err
+}
+
+func MarkSent(db *gorm.DB, m *entities.OutboxMessage, at time.Time) error {
+	m.Status = entities.OutboxSent
+	m.SentAt = &at
+	m.Attempts++
+	return db.Model(m).Updates(map[string]interface{}{
+		"status": m.Status, "sent_at": at, "attempts": m.Attempts, "last_error": "",
+	}).Error
+}
+
+// MarkFailed records a failed attempt and when to try again, or, when next
+// is nil, gives up on the message.
+func MarkFailed(db *gorm.DB, m *entities.OutboxMessage, cause error, next *time.Time) error {
+	m.Attempts++
+	m.LastError = cause.Error()
+	if len(m.LastError) > 500 {
+		m.LastError = m.LastError[:500]
+	}
+	updates := map[string]interface{}{"attempts": m.Attempts, "last_error": m.LastError}
+	if next == nil {
+		m.Status = entities.OutboxFailed
+		updates["status"] = m.Status
+	} else {
+		m.NextAttemptAt = *next
+		updates["next_attempt_at"] = *next
+	}
+	return db.Model(m).Updates(updates).Error
+}
+
+// DeleteSent removes messages sent before cutoff and reports how many.
+func DeleteSent(db *gorm.DB, cutoff time.Time) (int64, error) {
+	res := db.Where("status = ? AND sent_at < ?", entities.OutboxSent, cutoff).
+		Delete(&entities.OutboxMessage{})
+	return res.RowsAffected, res.Error
+}
@@ -1,14 +1,54 @@
 package entities
 
-import "time"
+import (
+	"time"
+
+	categoryEntities "ecommerce-app/domain/categories/entities"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 type Product struct {
-	ID          string  `gorm:"primaryKey"`
-	Name        string  `gorm:"size:255;not null"`
-	Category    string  `gorm:"size:100"`
-	Description string  `gorm:"size:500"`
-	Price       float64 `gorm:"not null"`
-	Stock       int     `gorm:"not null;default:0"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-}
\ No newline at end of file
+	ID          string                     `gorm:"primaryKey"`
+	Name        string                     `gorm:"size:255;not null"`
+	Category    string                     `gorm:"size:100"`
+	CategoryID  *string                    `gorm:"index;size:36"`
+	CategoryRef *categoryEntities.Category `gorm:"foreignKey:CategoryID;constraint:OnDelete:SET NULL"`
+	Description string                     `gorm:"size:500"`
+	Price       float64                    `gorm:"not null"`
+	Stock       int                        `gorm:"not null;default:0"`
+	// ReorderThreshold is the stock level at or below which staff are
+	// alerted; zero disables alerts. LowStockAlerted records that the current
+	// dip has already been reported.
+	ReorderThreshold int  `gorm:"not null;default:0"`
+	LowStockAlerted  bool `gorm:"not null;default:false"`
+	// FulfilmentPolicy, when set, overrides the order's policy for lines of
+	// this product that cannot be fully covered.
+	FulfilmentPolicy string `gorm:"size:20;not null;default:''"`
+	// Version is bumped on every write; see shared/concurrency.
+	Version   int `gorm:"not null;default:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Rank      float64 `gorm:"->;-:migration"`
+}
+
+func (p *Product) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// NeedsLowStockAlert reports whether stock is at or under the reorder
+// threshold and this dip has not been reported yet.
+func (p *Product) NeedsLowStockAlert() bool {
+	return p.ReorderThreshold > 0 && p.Stock <= p.ReorderThreshold && !p.LowStockAlerted
+}
+
+// LowStockRecovered reports whether a reported dip is over, because stock has
+// climbed back above the threshold or alerts were turned off, so the next dip
+// alerts again.
+func (p *Product) LowStockRecovered() bool {
+	return p.LowStockAlerted && (p.ReorderThreshold <= 0 || p.Stock > p.ReorderThreshold)
+}
@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// StockSubscription asks for one message when a product (or one of its
+// variants, when VariantID is set) comes back in stock. NotifiedAt is set once
+// that message has been claimed for sending.
+type StockSubscription struct {
+	ID         string `gorm:"primaryKey;size:36"`
+	UserID     string `gorm:"size:36;not null;uniqueIndex:idx_stock_subscriptions_user_item"`
+	ProductID  string `gorm:"size:36;not null;uniqueIndex:idx_stock_subscriptions_user_item;index"`
+	VariantID  string `gorm:"size:36;not null;default:'';uniqueIndex:idx_stock_subscriptions_user_item"`
+	NotifiedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (s *StockSubscription) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
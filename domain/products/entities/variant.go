@@ -0,0 +1,67 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// VariantOptions are the attributes that tell variants apart, e.g.
+// {"size": "M", "color": "red"}. They are stored as jsonb.
+type VariantOptions map[string]string
+
+func (o VariantOptions) Value() (driver.Value, error) {
+	if o == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(o)
+	return string(b), err
+}
+
+func (o *VariantOptions) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*o = VariantOptions{}
+		return nil
+	default:
+		return errors.New("unsupported type for VariantOptions")
+	}
+	return json.Unmarshal(b, o)
+}
+
+// ProductVariant is a sellable SKU of a product. Price overrides the product
+// price when set. A product with variants keeps Product.Stock equal to the sum
+// of its variants' stock.
+type ProductVariant struct {
+	ID        string         `gorm:"primaryKey;size:36"`
+	ProductID string         `gorm:"index;size:36;not null"`
+	SKU       string         `gorm:"uniqueIndex;size:64;not null"`
+	Options   VariantOptions `gorm:"type:jsonb;not null;default:'{}'"`
+	Price     *float64
+	Stock     int `gorm:"not null;default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v *ProductVariant) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (v *ProductVariant) EffectivePrice(base float64) float64 {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return base
+}
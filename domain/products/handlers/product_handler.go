@@ -3,7 +3,12 @@ package handlers
 import (
 	"net/http"
 
+	categoryRepositories "ecommerce-app/domain/categories/repositories"
+	"ecommerce-app/domain/products/models/request"
+	"ecommerce-app/domain/products/repositories"
 	"ecommerce-app/domain/products/usecase"
+	"ecommerce-app/shared/concurrency"
+	"ecommerce-app/shared/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,12 +21,15 @@ func NewProductHandler(uc *usecase.ProductUsecase) *ProductHandler {
 }
 
 func (h *ProductHandler) GetProducts(c *gin.Context) {
-	name := c.Query("name")
-	category := c.Query("category")
+	var req request.ListProductsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	res, err := h.uc.GetProducts(name, category)
+	res, err := h.uc.GetProducts(&req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeProductError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, res)
@@ -37,3 +45,123 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, res)
 }
+
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var req request.CreateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.CreateProduct(&req, middleware.Actor(c))
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	var req request.UpdateProductRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.UpdateProduct(c.Param("id"), &req, middleware.Actor(c))
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ProductHandler) AdjustStock(c *gin.Context) {
+	var req request.AdjustStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.AdjustStock(c.Param("id"), &req, middleware.Actor(c))
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	if err := h.uc.DeleteProduct(c.Param("id"), middleware.Actor(c)); err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProductHandler) CreateVariant(c *gin.Context) {
+	var req request.CreateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.CreateVariant(c.Param("id"), &req, middleware.Actor(c))
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *ProductHandler) UpdateVariant(c *gin.Context) {
+	var req request.UpdateVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.UpdateVariant(c.Param("id"), c.Param("variantId"), &req)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ProductHandler) AdjustVariantStock(c *gin.Context) {
+	var req request.AdjustStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.AdjustVariantStock(c.Param("id"), c.Param("variantId"), &req, middleware.Actor(c))
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *ProductHandler) DeleteVariant(c *gin.Context) {
+	if err := h.uc.DeleteVariant(c.Param("id"), c.Param("variantId"), middleware.Actor(c)); err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeProductError(c *gin.Context, err error) {
+	switch {
+	case usecase.IsValidationError(err), err == categoryRepositories.ErrCategoryNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err == repositories.ErrProductNotFound, err == repositories.ErrVariantNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err == repositories.ErrInsufficientStock, err == repositories.ErrStockManagedByVariants,
+		err == repositories.ErrProductHasStock, err == repositories.ErrProductInUse, concurrency.IsConflict(err):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
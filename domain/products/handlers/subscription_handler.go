@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"ecommerce-app/domain/products/models/request"
+	"ecommerce-app/domain/products/usecase"
+	"ecommerce-app/shared/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	uc *usecase.SubscriptionUsecase
+}
+
+func NewSubscriptionHandler(uc *usecase.SubscriptionUsecase) *SubscriptionHandler {
+	return &SubscriptionHandler{uc}
+}
+
+func (h *SubscriptionHandler) Subscribe(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	// Every field is optional, so a request without a body subscribes to the
+	// whole product.
+	var req request.SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.uc.Subscribe(userID, c.Param("id"), &req)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *SubscriptionHandler) Unsubscribe(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.uc.Unsubscribe(userID, c.Param("id"), c.Query("variant_id")); err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package request
+
+type CreateProductRequest struct {
+	Name             string  `json:"name" binding:"required,max=255"`
+	CategoryID       string  `json:"category_id" binding:"required,uuid"`
+	Description      string  `json:"description" binding:"max=500"`
+	Price            float64 `json:"price" binding:"required,gt=0"`
+	Stock            int     `json:"stock" binding:"min=0"`
+	ReorderThreshold int     `json:"reorder_threshold" binding:"min=0"`
+	FulfilmentPolicy string  `json:"fulfilment_policy,omitempty" binding:"omitempty,oneof=cancel_all partial backorder"`
+}
+
+type UpdateProductRequest struct {
+	Name             *string  `json:"name,omitempty" binding:"omitempty,max=255"`
+	CategoryID       *string  `json:"category_id,omitempty" binding:"omitempty,uuid"`
+	Description      *string  `json:"description,omitempty" binding:"omitempty,max=500"`
+	Price            *float64 `json:"price,omitempty" binding:"omitempty,gt=0"`
+	Stock            *int     `json:"stock,omitempty" binding:"omitempty,min=0"`
+	ReorderThreshold *int     `json:"reorder_threshold,omitempty" binding:"omitempty,min=0"`
+	FulfilmentPolicy *string  `json:"fulfilment_policy,omitempty" binding:"omitempty,oneof=cancel_all partial backorder"`
+	// Version, when sent, must match the product's current version.
+	Version *int `json:"version,omitempty"`
+}
+
+type AdjustStockRequest struct {
+	Delta int `json:"delta" binding:"required"`
+}
+
+type ListProductsRequest struct {
+	Query    string `form:"q"`
+	Name     string `form:"name"`
+	Category string `form:"category"`
+	Sort     string `form:"sort"`
+	Limit    int    `form:"limit"`
+	Cursor   string `form:"cursor"`
+}
+
+type CreateVariantRequest struct {
+	SKU     string            `json:"sku" binding:"required,max=64"`
+	Options map[string]string `json:"options"`
+	Price   *float64          `json:"price,omitempty" binding:"omitempty,gt=0"`
+	Stock   int               `json:"stock" binding:"min=0"`
+}
+
+type UpdateVariantRequest struct {
+	SKU     *string           `json:"sku,omitempty" binding:"omitempty,max=64"`
+	Options map[string]string `json:"options,omitempty"`
+	Price   *float64          `json:"price,omitempty" binding:"omitempty,gt=0"`
+}
+
+type SubscribeRequest struct {
+	VariantID string `json:"variant_id,omitempty" binding:"omitempty,uuid"`
+}
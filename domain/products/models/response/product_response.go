@@ -3,10 +3,37 @@ package response
 import "time"
 
 type ProductResponse struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	Category         string            `json:"category"`
+	CategoryID       *string           `json:"category_id,omitempty"`
+	Description      string            `json:"description"`
+	Price            float64           `json:"price"`
+	Stock            int               `json:"stock"`
+	ReorderThreshold int               `json:"reorder_threshold"`
+	FulfilmentPolicy string            `json:"fulfilment_policy,omitempty"`
+	Version          int               `json:"version"`
+	Variants         []VariantResponse `json:"variants,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
+
+type VariantResponse struct {
+	ID        string            `json:"id"`
+	ProductID string            `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	Price     float64           `json:"price"`
+	Stock     int               `json:"stock"`
+}
+
+type ProductListResponse struct {
+	Items      []ProductResponse `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type SubscriptionResponse struct {
 	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Category  string    `json:"category"`
-	Price     float64   `json:"price"`
-	Stock     int       `json:"stock"`
+	ProductID string    `json:"product_id"`
+	VariantID string    `json:"variant_id,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+}
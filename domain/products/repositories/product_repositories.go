@@ -1,14 +1,65 @@
 package repositories
 
 import (
+	inventoryEntities "ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	orderEntities "ecommerce-app/domain/orders/entities"
+	outboxRepo "ecommerce-app/domain/outbox/repositories"
 	"ecommerce-app/domain/products/entities"
+	"ecommerce-app/events"
+	"ecommerce-app/shared/concurrency"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrProductNotFound = errors.New("product not found")
+var ErrInsufficientStock = errors.New("insufficient stock")
+var ErrVariantNotFound = errors.New("variant not found")
+var ErrStockManagedByVariants = errors.New("stock of a product with variants is managed per variant")
+var ErrProductHasStock = errors.New("product stock must be zero before adding variants")
+var ErrProductInUse = errors.New("product has open orders or reservations; wait for them to settle before deleting it")
+
+var sortColumns = map[string]bool{"price": true, "name": true, "created_at": true, "relevance": true}
+
+// ProductCursor holds the sort keys of the last product on a page; the next
+// page starts strictly after it in (sort column, id) order.
+type ProductCursor struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Price     float64   `json:"price,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Rank      float64   `json:"rank,omitempty"`
+}
+
+type ProductFilter struct {
+	Query       string
+	Name        string
+	CategoryIDs []string
+	SortField   string
+	SortDesc    bool
+	Limit       int
+	After       *ProductCursor
+}
+
 type ProductRepository interface {
-	FindAll(name, category string) ([]entities.Product, error)
+	FindAll(f ProductFilter) ([]entities.Product, error)
 	FindByID(id string) (*entities.Product, error)
+	Create(p *entities.Product, e ledger.Entry) error
+	Update(p *entities.Product, e ledger.Entry) error
+	Delete(id string, e ledger.Entry) error
+	AdjustStock(id string, delta int, e ledger.Entry) (*entities.Product, error)
+	FindVariantsByProductID(productID string) ([]entities.ProductVariant, error)
+	FindVariantByID(id string) (*entities.ProductVariant, error)
+	CreateVariant(v *entities.ProductVariant, e ledger.Entry) error
+	UpdateVariant(v *entities.ProductVariant) error
+	DeleteVariant(id string, e ledger.Entry) error
+	AdjustVariantStock(id string, delta int, e ledger.Entry) (*entities.ProductVariant, *entities.Product, error)
 }
 type GormProductRepo struct {
 	db *gorm.DB
@@ -17,15 +68,64 @@ type GormProductRepo struct {
 func NewGormProductRepo(db *gorm.DB) ProductRepository {
 	return &GormProductRepo{db}
 }
-func (r *GormProductRepo) FindAll(name, category string) ([]entities.Product, error) {
+func (r *GormProductRepo) FindAll(f ProductFilter) ([]entities.Product, error) {
 	var products []entities.Product
 	q := r.db.Model(&entities.Product{})
 
-	if name != "" {
-		q = q.Where("name ILIKE ?", "%"+name+"%")
+	if f.Name != "" {
+		q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+	}
+	if f.CategoryIDs != nil {
+		q = q.Where("category_id IN ?", f.CategoryIDs)
+	}
+
+	var rankSQL string
+	var rankArgs []interface{}
+	if f.Query != "" {
+		rankSQL, rankArgs = "word_similarity(?, name)", []interface{}{f.Query}
+		match, matchArgs := "? <% name", []interface{}{f.Query}
+		if tsq := buildTSQuery(f.Query); tsq != "" {
+			rankSQL = "ts_rank(search_vector, to_tsquery('english', ?)) + " + rankSQL
+			rankArgs = append([]interface{}{tsq}, rankArgs...)
+			match = "search_vector @@ to_tsquery('english', ?) OR " + match
+			matchArgs = append([]interface{}{tsq}, matchArgs...)
+		}
+		rankSQL = "(" + rankSQL + ")::float8"
+		q = q.Select("products.*, "+rankSQL+" AS rank", rankArgs...).Where("("+match+")", matchArgs...)
+	}
+
+	col := f.SortField
+	if !sortColumns[col] || (col == "relevance" && f.Query == "") {
+		col = "created_at"
+	}
+	dir, cmp := "ASC", ">"
+	if f.SortDesc {
+		dir, cmp = "DESC", "<"
 	}
-	if category != "" {
-		q = q.Where("category = ?", category)
+	if f.After != nil {
+		keyset, args := col, []interface{}{}
+		switch col {
+		case "price":
+			args = append(args, f.After.Price)
+		case "name":
+			args = append(args, f.After.Name)
+		case "relevance":
+			keyset = rankSQL
+			args = append(args, rankArgs...)
+			args = append(args, f.After.Rank)
+		default:
+			args = append(args, f.After.CreatedAt)
+		}
+		args = append(args, f.After.ID)
+		q = q.Where(fmt.Sprintf("(%s, id) %s (?, ?)", keyset, cmp), args...)
+	}
+	if col == "relevance" {
+		q = q.Order(fmt.Sprintf("rank %s, id %s", dir, dir))
+	} else {
+		q = q.Order(fmt.Sprintf("%s %s, id %s", col, dir, dir))
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
 	}
 
 	err := q.Find(&products).Error
@@ -35,7 +135,338 @@ func (r *GormProductRepo) FindAll(name, category string) ([]entities.Product, er
 func (r *GormProductRepo) FindByID(id string) (*entities.Product, error) {
 	var p entities.Product
 	if err := r.db.First(&p, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
 		return nil, err
 	}
 	return &p, nil
-}
\ No newline at end of file
+}
+
+func (r *GormProductRepo) Create(p *entities.Product, e ledger.Entry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, p.ID, "", "", p.Stock, e); err != nil {
+			return err
+		}
+		return TrackLowStock(tx, p)
+	})
+}
+
+func (r *GormProductRepo) Update(p *entities.Product, e ledger.Entry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var current entities.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", p.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		if err := concurrency.Update(tx, "product", p.ID, p, &p.Version); err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, p.ID, "", "", p.Stock-current.Stock, e); err != nil {
+			return err
+		}
+		if err := enqueueStockEvents(tx, p.ID, "", p.Stock-current.Stock, p.Stock, 0); err != nil {
+			return err
+		}
+		return TrackLowStock(tx, p)
+	})
+}
+
+// Delete removes a product with its variants, warehouse levels and stock
+// subscriptions. It fails with ErrProductInUse while orders still waiting
+// for stock or active reservations point at the product, since the worker
+// could no longer settle them.
+func (r *GormProductRepo) Delete(id string, e ledger.Entry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var prod entities.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		inUse, err := productInUse(tx, id)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrProductInUse
+		}
+		if err := tx.Delete(&prod).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entities.ProductVariant{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&inventoryEntities.StockLevel{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entities.StockSubscription{}, "product_id = ?", id).Error; err != nil {
+			return err
+		}
+		return ledger.Record(tx, id, "", "", -prod.Stock, e)
+	})
+}
+
+// productInUse reports whether an order line still waiting for stock, or an
+// active reservation, refers to the product.
+func productInUse(tx *gorm.DB, id string) (bool, error) {
+	var lines int64
+	err := tx.Model(&orderEntities.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND order_items.status IN ?", id,
+			[]string{orderEntities.ItemPending, orderEntities.ItemBackordered}).
+		Where("orders.status IN ?", []orderEntities.OrderStatus{
+			orderEntities.StatusPending, orderEntities.StatusPaid, orderEntities.StatusBackordered}).
+		Count(&lines).Error
+	if err != nil || lines > 0 {
+		return lines > 0, err
+	}
+	var holds int64
+	err = tx.Model(&inventoryEntities.StockReservation{}).
+		Where("product_id = ? AND status = ?", id, inventoryEntities.ReservationActive).
+		Count(&holds).Error
+	return holds > 0, err
+}
+
+func (r *GormProductRepo) AdjustStock(id string, delta int, e ledger.Entry) (*entities.Product, error) {
+	var prod entities.Product
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		var variants int64
+		if err := tx.Model(&entities.ProductVariant{}).Where("product_id = ?", id).Count(&variants).Error; err != nil {
+			return err
+		}
+		if variants > 0 {
+			return ErrStockManagedByVariants
+		}
+		if prod.Stock+delta < 0 {
+			return ErrInsufficientStock
+		}
+		prod.Stock = prod.Stock + delta
+		if err := concurrency.Update(tx, "product", prod.ID, &prod, &prod.Version); err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, prod.ID, "", "", delta, e); err != nil {
+			return err
+		}
+		if err := enqueueStockEvents(tx, prod.ID, "", delta, prod.Stock, 0); err != nil {
+			return err
+		}
+		return TrackLowStock(tx, &prod)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &prod, nil
+}
+
+func (r *GormProductRepo) FindVariantsByProductID(productID string) ([]entities.ProductVariant, error) {
+	var variants []entities.ProductVariant
+	err := r.db.Where("product_id = ?", productID).Order("sku").Find(&variants).Error
+	return variants, err
+}
+
+func (r *GormProductRepo) FindVariantByID(id string) (*entities.ProductVariant, error) {
+	var v entities.ProductVariant
+	if err := r.db.First(&v, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVariantNotFound
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *GormProductRepo) CreateVariant(v *entities.ProductVariant, e ledger.Entry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var prod entities.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", v.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProductNotFound
+			}
+			return err
+		}
+		var variants int64
+		if err := tx.Model(&entities.ProductVariant{}).Where("product_id = ?", v.ProductID).Count(&variants).Error; err != nil {
+			return err
+		}
+		if variants == 0 && prod.Stock != 0 {
+			return ErrProductHasStock
+		}
+		if err := tx.Create(v).Error; err != nil {
+			return err
+		}
+		prod.Stock = prod.Stock + v.Stock
+		if err := concurrency.Update(tx, "product", prod.ID, &prod, &prod.Version); err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, prod.ID, v.ID, "", v.Stock, e); err != nil {
+			return err
+		}
+		if err := enqueueStockEvents(tx, prod.ID, v.ID, v.Stock, prod.Stock, v.Stock); err != nil {
+			return err
+		}
+		return TrackLowStock(tx, &prod)
+	})
+}
+
+// UpdateVariant saves everything but stock, which only moves through
+// AdjustVariantStock so the product total stays in step.
+func (r *GormProductRepo) UpdateVariant(v *entities.ProductVariant) error {
+	return r.db.Model(v).Select("sku", "options", "price").Updates(v).Error
+}
+
+func (r *GormProductRepo) DeleteVariant(id string, e ledger.Entry) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		v, err := lockVariant(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(v).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entities.Product{}).Where("id = ?", v.ProductID).
+			Updates(map[string]interface{}{"stock": gorm.Expr("stock - ?", v.Stock), "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, v.ProductID, v.ID, "", -v.Stock, e); err != nil {
+			return err
+		}
+		var prod entities.Product
+		if err := tx.First(&prod, "id = ?", v.ProductID).Error; err != nil {
+			return err
+		}
+		return TrackLowStock(tx, &prod)
+	})
+}
+
+func (r *GormProductRepo) AdjustVariantStock(id string, delta int, e ledger.Entry) (*entities.ProductVariant, *entities.Product, error) {
+	var v *entities.ProductVariant
+	var prod entities.Product
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if v, err = lockVariant(tx, id); err != nil {
+			return err
+		}
+		if v.Stock+delta < 0 {
+			return ErrInsufficientStock
+		}
+		v.Stock = v.Stock + delta
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entities.Product{}).Where("id = ?", v.ProductID).
+			Updates(map[string]interface{}{"stock": gorm.Expr("stock + ?", delta), "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&prod, "id = ?", v.ProductID).Error; err != nil {
+			return err
+		}
+		if err := ledger.Record(tx, v.ProductID, v.ID, "", delta, e); err != nil {
+			return err
+		}
+		if err := enqueueStockEvents(tx, v.ProductID, v.ID, delta, prod.Stock, v.Stock); err != nil {
+			return err
+		}
+		return TrackLowStock(tx, &prod)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, &prod, nil
+}
+
+// enqueueStockEvents queues what a change of delta units announces in the
+// outbox: restocked when units were added, so waiting backorders are filled,
+// and back-in-stock for the product and the variant when either went from
+// none to some. productAfter and variantAfter are the stock as written.
+func enqueueStockEvents(tx *gorm.DB, productID, variantID string, delta, productAfter, variantAfter int) error {
+	if err := events.EnqueueRestocked(tx, productID, variantID, delta); err != nil {
+		return err
+	}
+	if err := events.EnqueueIfBackInStock(tx, productID, "", productAfter-delta, productAfter); err != nil {
+		return err
+	}
+	if variantID == "" {
+		return nil
+	}
+	return events.EnqueueIfBackInStock(tx, productID, variantID, variantAfter-delta, variantAfter)
+}
+
+// TrackLowStock is called in the transaction of every write that changes a
+// product's stock, once the row is written, with prod holding the row as
+// written. The first time stock is at or under the reorder threshold it marks
+// the product and queues a low-stock alert in the outbox, so the alert is sent
+// if and only if the write commits; once stock climbs back above the
+// threshold the mark is cleared so the next dip alerts again.
+func TrackLowStock(tx *gorm.DB, prod *entities.Product) error {
+	switch {
+	case prod.NeedsLowStockAlert():
+		prod.LowStockAlerted = true
+	case prod.LowStockRecovered():
+		prod.LowStockAlerted = false
+	default:
+		return nil
+	}
+	if err := tx.Model(&entities.Product{}).Where("id = ?", prod.ID).
+		UpdateColumn("low_stock_alerted", prod.LowStockAlerted).Error; err != nil {
+		return err
+	}
+	if !prod.LowStockAlerted {
+		return nil
+	}
+	return outboxRepo.Enqueue(tx, events.Exchange(), events.LowStockRoutingKey(), events.LowStockQueue(), events.LowStockPayload{
+		ProductID: prod.ID,
+		Name:      prod.Name,
+		Stock:     prod.Stock,
+		Threshold: prod.ReorderThreshold,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// lockVariant locks the parent product row before the variant row, the same
+// order the inventory worker uses, so the two never deadlock each other.
+func lockVariant(tx *gorm.DB, id string) (*entities.ProductVariant, error) {
+	var v entities.ProductVariant
+	if err := tx.First(&v, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVariantNotFound
+		}
+		return nil, err
+	}
+	var prod entities.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", v.ProductID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&v, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVariantNotFound
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// buildTSQuery turns free text into a prefix-matching tsquery such as
+// "red:* & shoe:*". Only letters and digits survive, so the result is always
+// valid to_tsquery input.
+func buildTSQuery(text string) string {
+	terms := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, t := range terms {
+		terms[i] = t + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"ecommerce-app/domain/products/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SubscriptionRepository interface {
+	Subscribe(s *entities.StockSubscription) error
+	Unsubscribe(userID, productID, variantID string) error
+	FindPending(productID, variantID string) ([]entities.StockSubscription, error)
+	MarkNotified(id string) (bool, error)
+}
+
+type GormSubscriptionRepo struct {
+	db *gorm.DB
+}
+
+func NewGormSubscriptionRepo(db *gorm.DB) SubscriptionRepository {
+	return &GormSubscriptionRepo{db}
+}
+
+// Subscribe creates the subscription, or re-arms an existing one that has
+// already been notified. Either way s is filled in with the stored row.
+func (r *GormSubscriptionRepo) Subscribe(s *entities.StockSubscription) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "product_id"}, {Name: "variant_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"notified_at": nil, "updated_at": time.Now()}),
+	}).Create(s).Error
+	if err != nil {
+		return err
+	}
+	// On conflict s still holds the ID generated for the insert, so the
+	// stored row is read into a fresh struct.
+	var stored entities.StockSubscription
+	if err := r.db.Where("user_id = ? AND product_id = ? AND variant_id = ?", s.UserID, s.ProductID, s.VariantID).
+		First(&stored).Error; err != nil {
+		return err
+	}
+	*s = stored
+	return nil
+}
+
+func (r *GormSubscriptionRepo) Unsubscribe(userID, productID, variantID string) error {
+	return r.db.Where("user_id = ? AND product_id = ? AND variant_id = ?", userID, productID, variantID).
+		Delete(&entities.StockSubscription{}).Error
+}
+
+func (r *GormSubscriptionRepo) FindPending(productID, variantID string) ([]entities.StockSubscription, error) {
+	var subs []entities.StockSubscription
+	err := r.db.Where("product_id = ? AND variant_id = ? AND notified_at IS NULL", productID, variantID).
+		Order("created_at").Find(&subs).Error
+	return subs, err
+}
+
+// MarkNotified claims a subscription for sending. It reports false when
+// another consumer claimed it first, so each subscriber is messaged once.
+func (r *GormSubscriptionRepo) MarkNotified(id string) (bool, error) {
+	res := r.db.Model(&entities.StockSubscription{}).
+		Where("id = ? AND notified_at IS NULL", id).
+		Update("notified_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
@@ -1,51 +1,118 @@
 package usecase
 
 import (
+	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	categoryEntities "ecommerce-app/domain/categories/entities"
+	categoryRepositories "ecommerce-app/domain/categories/repositories"
+	inventoryEntities "ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	"ecommerce-app/domain/products/entities"
+	"ecommerce-app/domain/products/models/request"
 	"ecommerce-app/domain/products/models/response"
 	"ecommerce-app/domain/products/repositories"
+	"ecommerce-app/shared/cache"
+	"ecommerce-app/shared/concurrency"
+	"ecommerce-app/shared/pagination"
 
-	"github.com/redis/go-redis/v9"
 	"context"
 )
 
+var ErrInvalidName = errors.New("product name is required")
+var ErrInvalidPrice = errors.New("price must be greater than zero")
+var ErrInvalidStock = errors.New("stock cannot be negative")
+var ErrInvalidCategory = errors.New("category is required")
+var ErrInvalidDelta = errors.New("stock delta cannot be zero")
+var ErrInvalidSort = errors.New("sort must be one of price, name, created_at or relevance (with q), optionally prefixed with -")
+var ErrInvalidSKU = errors.New("sku is required")
+
+const (
+	defaultPageSize  = 20
+	defaultSort      = "-created_at"
+	defaultQuerySort = "-relevance"
+)
+
+// listCursor is the decoded form of the opaque cursor handed to clients. The
+// sort it was issued for is kept so it cannot be replayed against another.
+type listCursor struct {
+	Sort string `json:"sort"`
+	repositories.ProductCursor
+}
+
 type ProductUsecase struct {
-	repo     repositories.ProductRepository
-	cache    *redis.Client
+	repo       repositories.ProductRepository
+	categories categoryRepositories.CategoryRepository
+	cache      *cache.ProductCache
 }
 
-func NewProductUsecase(repo repositories.ProductRepository, cache *redis.Client) *ProductUsecase {
-	return &ProductUsecase{repo, cache}
+func NewProductUsecase(repo repositories.ProductRepository, categories categoryRepositories.CategoryRepository, cache *cache.ProductCache) *ProductUsecase {
+	return &ProductUsecase{repo, categories, cache}
 }
 
-func (uc *ProductUsecase) GetProducts(name, category string) ([]response.ProductResponse, error) {
+func (uc *ProductUsecase) GetProducts(req *request.ListProductsRequest) (*response.ProductListResponse, error) {
+	filter, err := buildProductFilter(req)
+	if err != nil {
+		return nil, err
+	}
+	// ?category= takes a slug, or a category name as it was before
+	// categories had slugs: "Home Goods" slugifies to "home-goods".
+	category := categoryEntities.Slugify(req.Category)
+
 	ctx := context.Background()
-	cacheKey := fmt.Sprintf("products:%s:%s", name, category)
+	cacheKey, keyErr := uc.cache.ListKey(ctx, req.Query, req.Name, category, sortKey(filter), strconv.Itoa(filter.Limit), req.Cursor)
+
+	if keyErr == nil {
+		var cached response.ProductListResponse
+		if uc.cache.Get(ctx, cacheKey, &cached) {
+			return &cached, nil
+		}
+	}
+
+	limit := filter.Limit
+	res := &response.ProductListResponse{Items: make([]response.ProductResponse, 0, limit)}
 
-	if data, err := uc.cache.Get(ctx, cacheKey).Result(); err == nil {
-		var cached []response.ProductResponse
-		_ = json.Unmarshal([]byte(data), &cached)
-		return cached, nil
+	if category != "" {
+		cat, err := uc.categories.FindBySlug(category)
+		if err == categoryRepositories.ErrCategoryNotFound {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filter.CategoryIDs, err = uc.categories.FindDescendantIDs(cat.ID); err != nil {
+			return nil, err
+		}
 	}
 
-	products, err := uc.repo.FindAll(name, category)
+	filter.Limit = limit + 1
+	products, err := uc.repo.FindAll(filter)
 	if err != nil {
 		return nil, err
 	}
 
-	res := make([]response.ProductResponse, 0)
-	for _, p := range products {
-		res = append(res, response.ProductResponse{
-			ID: p.ID, Name: p.Name, Category: p.Category, Price: p.Price, Stock: p.Stock,
-			CreatedAt: p.CreatedAt,
+	if len(products) > limit {
+		products = products[:limit]
+		last := products[limit-1]
+		res.NextCursor = encodeCursor(listCursor{
+			Sort: sortKey(filter),
+			ProductCursor: repositories.ProductCursor{
+				ID: last.ID, Name: last.Name, Price: last.Price, CreatedAt: last.CreatedAt, Rank: last.Rank,
+			},
 		})
 	}
+	for _, p := range products {
+		res.Items = append(res.Items, toProductResponse(&p))
+	}
 
-	bytes, _ := json.Marshal(res)
-	uc.cache.Set(ctx, cacheKey, bytes, 5*time.Minute)
+	if keyErr == nil {
+		uc.cache.Set(ctx, cacheKey, res, 5*time.Minute)
+	}
 
 	return res, nil
 }
@@ -56,8 +123,338 @@ func (uc *ProductUsecase) GetProduct(id string) (*response.ProductResponse, erro
 		return nil, err
 	}
 
-	return &response.ProductResponse{
-		ID: p.ID, Name: p.Name, Category: p.Category, Price: p.Price, Stock: p.Stock,
-		CreatedAt: p.CreatedAt,
-	}, nil
-}
\ No newline at end of file
+	variants, err := uc.repo.FindVariantsByProductID(p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := toProductResponse(p)
+	for i := range variants {
+		res.Variants = append(res.Variants, toVariantResponse(&variants[i], p.Price))
+	}
+	return &res, nil
+}
+
+func (uc *ProductUsecase) CreateProduct(req *request.CreateProductRequest, actor string) (*response.ProductResponse, error) {
+	p := &entities.Product{
+		Name:             strings.TrimSpace(req.Name),
+		Description:      strings.TrimSpace(req.Description),
+		Price:            req.Price,
+		Stock:            req.Stock,
+		ReorderThreshold: req.ReorderThreshold,
+		FulfilmentPolicy: req.FulfilmentPolicy,
+	}
+	if err := uc.assignCategory(p, req.CategoryID); err != nil {
+		return nil, err
+	}
+	if err := validateProduct(p); err != nil {
+		return nil, err
+	}
+
+	if err := uc.repo.Create(p, ledger.Entry{Reason: inventoryEntities.MovementInitial, Actor: actor}); err != nil {
+		return nil, err
+	}
+	uc.invalidateCache()
+
+	res := toProductResponse(p)
+	return &res, nil
+}
+
+func (uc *ProductUsecase) UpdateProduct(id string, req *request.UpdateProductRequest, actor string) (*response.ProductResponse, error) {
+	p, err := uc.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := concurrency.Check("product", p.ID, req.Version, p.Version); err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		p.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.CategoryID != nil {
+		if err := uc.assignCategory(p, *req.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+	if req.Description != nil {
+		p.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Price != nil {
+		p.Price = *req.Price
+	}
+	if req.Stock != nil && *req.Stock != p.Stock {
+		variants, err := uc.repo.FindVariantsByProductID(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(variants) > 0 {
+			return nil, repositories.ErrStockManagedByVariants
+		}
+		p.Stock = *req.Stock
+	}
+	if req.ReorderThreshold != nil && *req.ReorderThreshold != p.ReorderThreshold {
+		p.ReorderThreshold = *req.ReorderThreshold
+		p.LowStockAlerted = false
+	}
+	if req.FulfilmentPolicy != nil {
+		p.FulfilmentPolicy = *req.FulfilmentPolicy
+	}
+	if err := validateProduct(p); err != nil {
+		return nil, err
+	}
+
+	if err := uc.repo.Update(p, ledger.Entry{Reason: inventoryEntities.MovementAdjustment, Actor: actor, Note: "product update"}); err != nil {
+		return nil, err
+	}
+	uc.invalidateCache()
+
+	res := toProductResponse(p)
+	return &res, nil
+}
+
+func (uc *ProductUsecase) AdjustStock(id string, req *request.AdjustStockRequest, actor string) (*response.ProductResponse, error) {
+	if req.Delta == 0 {
+		return nil, ErrInvalidDelta
+	}
+
+	p, err := uc.repo.AdjustStock(id, req.Delta, ledger.Entry{Reason: inventoryEntities.MovementAdjustment, Actor: actor})
+	if err != nil {
+		return nil, err
+	}
+	uc.invalidateCache()
+
+	res := toProductResponse(p)
+	return &res, nil
+}
+
+func (uc *ProductUsecase) DeleteProduct(id, actor string) error {
+	if err := uc.repo.Delete(id, ledger.Entry{Reason: inventoryEntities.MovementAdjustment, Actor: actor, Note: "product deleted"}); err != nil {
+		return err
+	}
+	uc.invalidateCache()
+	return nil
+}
+
+func (uc *ProductUsecase) CreateVariant(productID string, req *request.CreateVariantRequest, actor string) (*response.VariantResponse, error) {
+	p, err := uc.repo.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &entities.ProductVariant{
+		ProductID: p.ID,
+		SKU:       strings.TrimSpace(req.SKU),
+		Options:   entities.VariantOptions(req.Options),
+		Price:     req.Price,
+		Stock:     req.Stock,
+	}
+	if v.SKU == "" {
+		return nil, ErrInvalidSKU
+	}
+	if v.Stock < 0 {
+		return nil, ErrInvalidStock
+	}
+
+	if err := uc.repo.CreateVariant(v, ledger.Entry{Reason: inventoryEntities.MovementInitial, Actor: actor}); err != nil {
+		return nil, err
+	}
+	uc.invalidateCache()
+
+	res := toVariantResponse(v, p.Price)
+	return &res, nil
+}
+
+func (uc *ProductUsecase) UpdateVariant(productID, variantID string, req *request.UpdateVariantRequest) (*response.VariantResponse, error) {
+	p, v, err := uc.findVariant(productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SKU != nil {
+		v.SKU = strings.TrimSpace(*req.SKU)
+	}
+	if req.Options != nil {
+		v.Options = entities.VariantOptions(req.Options)
+	}
+	if req.Price != nil {
+		v.Price = req.Price
+	}
+	if v.SKU == "" {
+		return nil, ErrInvalidSKU
+	}
+
+	if err := uc.repo.UpdateVariant(v); err != nil {
+		return nil, err
+	}
+	uc.invalidateCache()
+
+	res := toVariantResponse(v, p.Price)
+	return &res, nil
+}
+
+func (uc *ProductUsecase) AdjustVariantStock(productID, variantID string, req *request.AdjustStockRequest, actor string) (*response.VariantResponse, error) {
+	if req.Delta == 0 {
+		return nil, ErrInvalidDelta
+	}
+	if _, _, err := uc.findVariant(productID, variantID); err != nil {
+		return nil, err
+	}
+
+	v, p, err := uc.repo.AdjustVariantStock(variantID, req.Delta, ledger.Entry{Reason: inventoryEntities.MovementAdjustment, Actor: actor})
+	if err != nil {
+		return nil, err
+	}
+	uc.invalidateCache()
+
+	res := toVariantResponse(v, p.Price)
+	return &res, nil
+}
+
+func (uc *ProductUsecase) DeleteVariant(productID, variantID, actor string) error {
+	if _, _, err := uc.findVariant(productID, variantID); err != nil {
+		return err
+	}
+	if err := uc.repo.DeleteVariant(variantID, ledger.Entry{Reason: inventoryEntities.MovementAdjustment, Actor: actor, Note: "variant deleted"}); err != nil {
+		return err
+	}
+	uc.invalidateCache()
+	return nil
+}
+
+func (uc *ProductUsecase) findVariant(productID, variantID string) (*entities.Product, *entities.ProductVariant, error) {
+	p, err := uc.repo.FindByID(productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := uc.repo.FindVariantByID(variantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v.ProductID != p.ID {
+		return nil, nil, repositories.ErrVariantNotFound
+	}
+	return p, v, nil
+}
+
+// assignCategory links p to the category and copies its canonical name, which
+// listings and the search index read without a join.
+func (uc *ProductUsecase) assignCategory(p *entities.Product, categoryID string) error {
+	if categoryID == "" {
+		return ErrInvalidCategory
+	}
+	cat, err := uc.categories.FindByID(categoryID)
+	if err != nil {
+		return err
+	}
+	p.CategoryID = &cat.ID
+	p.Category = cat.Name
+	return nil
+}
+
+func (uc *ProductUsecase) invalidateCache() {
+	if err := uc.cache.Invalidate(context.Background()); err != nil {
+		log.Printf("products: cache invalidation failed: %v", err)
+	}
+}
+
+func IsValidationError(err error) bool {
+	switch err {
+	case ErrInvalidName, ErrInvalidPrice, ErrInvalidStock, ErrInvalidCategory, ErrInvalidDelta,
+		ErrInvalidSort, ErrInvalidSKU, pagination.ErrInvalidLimit, pagination.ErrInvalidCursor:
+		return true
+	}
+	return false
+}
+
+func buildProductFilter(req *request.ListProductsRequest) (repositories.ProductFilter, error) {
+	f := repositories.ProductFilter{Query: strings.TrimSpace(req.Query), Name: req.Name}
+
+	var err error
+	if f.Limit, err = pagination.Limit(req.Limit, defaultPageSize); err != nil {
+		return f, err
+	}
+
+	sort := req.Sort
+	if sort == "" {
+		sort = defaultSort
+		if f.Query != "" {
+			sort = defaultQuerySort
+		}
+	}
+	f.SortDesc = strings.HasPrefix(sort, "-")
+	f.SortField = strings.TrimPrefix(sort, "-")
+	switch f.SortField {
+	case "price", "name", "created_at":
+	case "relevance":
+		if f.Query == "" {
+			return f, ErrInvalidSort
+		}
+	default:
+		return f, ErrInvalidSort
+	}
+
+	if req.Cursor != "" {
+		cur, err := decodeCursor(req.Cursor)
+		if err != nil || cur.Sort != sortKey(f) || cur.ID == "" {
+			return f, pagination.ErrInvalidCursor
+		}
+		f.After = &cur.ProductCursor
+	}
+	return f, nil
+}
+
+func sortKey(f repositories.ProductFilter) string {
+	if f.SortDesc {
+		return "-" + f.SortField
+	}
+	return f.SortField
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func validateProduct(p *entities.Product) error {
+	if p.Name == "" {
+		return ErrInvalidName
+	}
+	if p.Price <= 0 {
+		return ErrInvalidPrice
+	}
+	if p.Stock < 0 {
+		return ErrInvalidStock
+	}
+	if p.CategoryID == nil {
+		return ErrInvalidCategory
+	}
+	return nil
+}
+
+func toProductResponse(p *entities.Product) response.ProductResponse {
+	return response.ProductResponse{
+		ID: p.ID, Name: p.Name, Category: p.Category, CategoryID: p.CategoryID, Description: p.Description,
+		Price: p.Price, Stock: p.Stock, ReorderThreshold: p.ReorderThreshold,
+		FulfilmentPolicy: p.FulfilmentPolicy, Version: p.Version, CreatedAt: p.CreatedAt,
+	}
+}
+
+func toVariantResponse(v *entities.ProductVariant, basePrice float64) response.VariantResponse {
+	return response.VariantResponse{
+		ID: v.ID, ProductID: v.ProductID, SKU: v.SKU, Options: v.Options,
+		Price: v.EffectivePrice(basePrice), Stock: v.Stock,
+	}
+}
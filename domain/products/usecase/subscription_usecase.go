@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"ecommerce-app/domain/products/entities"
+	"ecommerce-app/domain/products/models/request"
+	"ecommerce-app/domain/products/models/response"
+	"ecommerce-app/domain/products/repositories"
+)
+
+type SubscriptionUsecase struct {
+	repo     repositories.SubscriptionRepository
+	products repositories.ProductRepository
+}
+
+func NewSubscriptionUsecase(repo repositories.SubscriptionRepository, products repositories.ProductRepository) *SubscriptionUsecase {
+	return &SubscriptionUsecase{repo, products}
+}
+
+func (uc *SubscriptionUsecase) Subscribe(userID, productID string, req *request.SubscribeRequest) (*response.SubscriptionResponse, error) {
+	p, err := uc.products.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+	if req.VariantID != "" {
+		v, err := uc.products.FindVariantByID(req.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		if v.ProductID != p.ID {
+			return nil, repositories.ErrVariantNotFound
+		}
+	}
+
+	s := &entities.StockSubscription{UserID: userID, ProductID: p.ID, VariantID: req.VariantID}
+	if err := uc.repo.Subscribe(s); err != nil {
+		return nil, err
+	}
+
+	return &response.SubscriptionResponse{
+		ID: s.ID, ProductID: s.ProductID, VariantID: s.VariantID, CreatedAt: s.CreatedAt,
+	}, nil
+}
+
+func (uc *SubscriptionUsecase) Unsubscribe(userID, productID, variantID string) error {
+	return uc.repo.Unsubscribe(userID, productID, variantID)
+}
@@ -7,16 +7,35 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	ID           string    `gorm:"primaryKey;size:36" json:"id"`
 	Name         string    `gorm:"size:100" json:"name"`
 	Email        string    `gorm:"uniqueIndex;size:100;not null" json:"email"`
 	PasswordHash string    `gorm:"not null" json:"-"`
+	Role         string    `gorm:"size:20;not null;default:customer" json:"role"`
+	Version      int       `gorm:"not null;default:1" json:"version"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	u.ID = uuid.New().String()
+	if u.Role == "" {
+		u.Role = RoleCustomer
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
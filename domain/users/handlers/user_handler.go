@@ -3,10 +3,12 @@ package handlers
 import (
 	"net/http"
 
+	"ecommerce-app/domain/users/repositories"
 	"ecommerce-app/domain/users/usecase"
 	modelsRequest "ecommerce-app/domain/users/models/request"
 	modelsResponse "ecommerce-app/domain/users/models/response"
 
+	"ecommerce-app/shared/concurrency"
 	"ecommerce-app/shared/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -41,6 +43,8 @@ func (h *UserHandler) Register(c *gin.Context) {
 		ID:        user.ID,
 		Name:      user.Name,
 		Email:     user.Email,
+		Role:      user.Role,
+		Version:   user.Version,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
@@ -92,8 +96,37 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	}
 	prof, err := h.uc.UpdateProfile(uid, &req)
 	if err != nil {
+		if concurrency.IsConflict(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, prof)
 }
+
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	var req modelsRequest.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prof, err := h.uc.UpdateRole(c.Param("id"), &req)
+	if err != nil {
+		if concurrency.IsConflict(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		switch err {
+		case usecase.ErrInvalidRole:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case repositories.ErrUserNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, prof)
+}
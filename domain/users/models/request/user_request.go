@@ -10,6 +10,13 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// Version, when sent, must match the profile's current version.
 type UpdateProfileRequest struct {
-	Name  *string `json:"name,omitempty"`
-}
\ No newline at end of file
+	Name    *string `json:"name,omitempty"`
+	Version *int    `json:"version,omitempty"`
+}
+
+type UpdateRoleRequest struct {
+	Role    string `json:"role" binding:"required"`
+	Version *int   `json:"version,omitempty"`
+}
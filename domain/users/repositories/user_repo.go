@@ -3,6 +3,7 @@ package repositories
 import (
 	"errors"
 	"ecommerce-app/domain/users/entities"
+	"ecommerce-app/shared/concurrency"
 
 	"gorm.io/gorm"
 )
@@ -46,6 +47,8 @@ func (r *GormUserRepo) FindByID(id string) (*entities.User, error) {
 	return &user, nil
 }
 
+// Update saves u if nobody else has changed the row since it was read, and
+// returns a *concurrency.ConflictError otherwise.
 func (r *GormUserRepo) Update(u *entities.User) error {
-	return r.db.Save(u).Error
+	return concurrency.Update(r.db, "user", u.ID, u, &u.Version)
 }
@@ -10,6 +10,7 @@ import (
 	modelsRequest "ecommerce-app/domain/users/models/request"
 	modelsResponse "ecommerce-app/domain/users/models/response"
 	"ecommerce-app/domain/users/repositories"
+	"ecommerce-app/shared/concurrency"
 	"ecommerce-app/shared/security"
 
 	"golang.org/x/crypto/bcrypt"
@@ -17,6 +18,7 @@ import (
 
 var ErrInvalidCredentials = errors.New("invalid credentials")
 var ErrEmailExists = errors.New("email already registered")
+var ErrInvalidRole = errors.New("invalid role")
 
 type UserUsecase struct {
 	repo repositories.UserRepository
@@ -38,6 +40,7 @@ func (uc *UserUsecase) Register(req *modelsRequest.RegisterRequest) (*entities.U
 		Name:         req.Name,
 		Email:        req.Email,
 		PasswordHash: string(hash),
+		Role:         entities.RoleCustomer,
 	}
 
 	if err := uc.repo.Create(user); err != nil {
@@ -64,7 +67,7 @@ func (uc *UserUsecase) Login(req *modelsRequest.LoginRequest) (string, time.Time
 		}
 	}
 
-	token, exp, err := security.GenerateToken(user.ID, time.Duration(expiry)*time.Minute)
+	token, exp, err := security.GenerateToken(user.ID, user.Role, time.Duration(expiry)*time.Minute)
 	return token, exp, err
 }
 
@@ -78,6 +81,8 @@ func (uc *UserUsecase) GetProfile(id string) (*modelsResponse.ProfileResponse, e
 		ID:        user.ID,
 		Name:      user.Name,
 		Email:     user.Email,
+		Role:      user.Role,
+		Version:   user.Version,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}, nil
@@ -89,17 +94,73 @@ func (uc *UserUsecase) UpdateProfile(id string, req *modelsRequest.UpdateProfile
 		return nil, err
 	}
 
+	if err := concurrency.Check("user", user.ID, req.Version, user.Version); err != nil {
+		return nil, err
+	}
 	if req.Name != nil {
 		user.Name = *req.Name
 	}
 
-	_ = uc.repo.Update(user)
+	if err := uc.repo.Update(user); err != nil {
+		return nil, err
+	}
 
 	return &modelsResponse.ProfileResponse{
 		ID:        user.ID,
 		Name:      user.Name,
 		Email:     user.Email,
+		Role:      user.Role,
+		Version:   user.Version,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}, nil
 }
+
+// UpdateRole changes a user's role. Tokens carry the role they were issued
+// with, so the change reaches requests once the user logs in again, at the
+// latest when their current token expires after JWT_EXPIRY_MINUTES.
+func (uc *UserUsecase) UpdateRole(id string, req *modelsRequest.UpdateRoleRequest) (*modelsResponse.ProfileResponse, error) {
+	if !entities.IsValidRole(req.Role) {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := uc.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := concurrency.Check("user", user.ID, req.Version, user.Version); err != nil {
+		return nil, err
+	}
+	user.Role = req.Role
+	if err := uc.repo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &modelsResponse.ProfileResponse{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Role:      user.Role,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}, nil
+}
+
+// GrantAdmin makes the account already registered under email an
+// administrator. It backs the grant-admin command, which is how the first
+// administrators are created before anyone can grant roles over the API;
+// registering never grants a role, since nothing proves the caller owns the
+// address.
+func (uc *UserUsecase) GrantAdmin(email string) (*entities.User, error) {
+	user, err := uc.repo.FindByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = entities.RoleAdmin
+	if err := uc.repo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
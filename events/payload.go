@@ -2,14 +2,17 @@ package events
 
 import "time"
 
+type OrderItemPayload struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
 type OrderPlacedPayload struct {
-	OrderID   string `json:"order_id"`
-	UserID    string `json:"user_id"`
-	Items     []struct {
-		ProductID string `json:"product_id"`
-		Quantity  int    `json:"quantity"`
-	} `json:"items"`
-	CreatedAt time.Time `json:"created_at"`
+	OrderID   string             `json:"order_id"`
+	UserID    string             `json:"user_id"`
+	Items     []OrderItemPayload `json:"items"`
+	CreatedAt time.Time          `json:"created_at"`
 }
 
 type OrderResultPayload struct {
@@ -19,3 +22,44 @@ type OrderResultPayload struct {
 	Reason  string `json:"reason,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// OrderCancelledPayload announces an order its owner cancelled. Restored
+// lists the units put back into stock.
+type OrderCancelledPayload struct {
+	OrderID   string             `json:"order_id"`
+	UserID    string             `json:"user_id"`
+	Restored  []OrderItemPayload `json:"restored,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// OrderBackorderedPayload announces an order that was accepted but is waiting
+// on stock. Waiting lists the units still to come for each line; the order is
+// confirmed with an order.confirmed event once they have all arrived.
+type OrderBackorderedPayload struct {
+	OrderID   string             `json:"order_id"`
+	UserID    string             `json:"user_id"`
+	Waiting   []OrderItemPayload `json:"waiting"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type BackInStockPayload struct {
+	ProductID string    `json:"product_id"`
+	VariantID string    `json:"variant_id,omitempty"`
+	Stock     int       `json:"stock"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type LowStockPayload struct {
+	ProductID string    `json:"product_id"`
+	Name      string    `json:"name"`
+	Stock     int       `json:"stock"`
+	Threshold int       `json:"threshold"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RestockedPayload struct {
+	ProductID string    `json:"product_id"`
+	VariantID string    `json:"variant_id,omitempty"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"os"
+	"time"
+
+	outboxRepo "ecommerce-app/domain/outbox/repositories"
+
+	"gorm.io/gorm"
+)
+
+func Exchange() string {
+	exchange := os.Getenv("RABBITMQ_EXCHANGE")
+	if exchange == "" {
+		exchange = "orders_direct"
+	}
+	return exchange
+}
+
+func OrderCancelledRoutingKey() string {
+	rk := os.Getenv("RABBITMQ_CANCELLED_ROUTING_KEY")
+	if rk == "" {
+		rk = "order.cancelled"
+	}
+	return rk
+}
+
+func OrderBackorderedRoutingKey() string {
+	rk := os.Getenv("RABBITMQ_BACKORDERED_ROUTING_KEY")
+	if rk == "" {
+		rk = "order.backordered"
+	}
+	return rk
+}
+
+func BackInStockRoutingKey() string {
+	rk := os.Getenv("RABBITMQ_BACK_IN_STOCK_ROUTING_KEY")
+	if rk == "" {
+		rk = "product.back_in_stock"
+	}
+	return rk
+}
+
+func LowStockRoutingKey() string {
+	rk := os.Getenv("RABBITMQ_LOW_STOCK_ROUTING_KEY")
+	if rk == "" {
+		rk = "inventory.low_stock"
+	}
+	return rk
+}
+
+func RestockedRoutingKey() string {
+	rk := os.Getenv("RABBITMQ_RESTOCKED_ROUTING_KEY")
+	if rk == "" {
+		rk = "inventory.restocked"
+	}
+	return rk
+}
+
+func OrderCancelledQueue() string {
+	q := os.Getenv("RABBITMQ_CANCELLED_QUEUE")
+	if q == "" {
+		q = "order_cancelled_queue"
+	}
+	return q
+}
+
+func RestockedQueue() string {
+	q := os.Getenv("RABBITMQ_RESTOCKED_QUEUE")
+	if q == "" {
+		q = "inventory_restocked_queue"
+	}
+	return q
+}
+
+func BackInStockQueue() string {
+	q := os.Getenv("RABBITMQ_BACK_IN_STOCK_QUEUE")
+	if q == "" {
+		q = "product_back_in_stock_queue"
+	}
+	return q
+}
+
+func LowStockQueue() string {
+	q := os.Getenv("RABBITMQ_LOW_STOCK_QUEUE")
+	if q == "" {
+		q = "inventory_low_stock_queue"
+	}
+	return q
+}
+
+// EnqueueIfBackInStock queues a back-in-stock event in the outbox inside tx
+// when an item's stock went from zero (or below) to positive, so it is sent
+// if and only if the stock change commits.
+func EnqueueIfBackInStock(tx *gorm.DB, productID, variantID string, before, after int) error {
+	if before > 0 || after <= 0 {
+		return nil
+	}
+	return outboxRepo.Enqueue(tx, Exchange(), BackInStockRoutingKey(), BackInStockQueue(), BackInStockPayload{
+		ProductID: productID,
+		VariantID: variantID,
+		Stock:     after,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// EnqueueRestocked queues a restocked event in the outbox inside tx when
+// quantity units of an item were added, so waiting backorders can be filled.
+func EnqueueRestocked(tx *gorm.DB, productID, variantID string, quantity int) error {
+	if quantity <= 0 {
+		return nil
+	}
+	return outboxRepo.Enqueue(tx, Exchange(), RestockedRoutingKey(), RestockedQueue(), RestockedPayload{
+		ProductID: productID,
+		VariantID: variantID,
+		Quantity:  quantity,
+		CreatedAt: time.Now().UTC(),
+	})
+}
@@ -2,7 +2,7 @@ module ecommerce-app
 
 go 1.23.4
 
-require github.com/jackc/pgx/v5 v5.7.6 // indirect
+require github.com/jackc/pgx/v5 v5.7.6
 
 require gorm.io/gorm v1.25.10
 
@@ -2,25 +2,52 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"ecommerce-app/config"
+	userEntities "ecommerce-app/domain/users/entities"
 	"ecommerce-app/domain/users/handlers"
 	"ecommerce-app/domain/users/repositories"
 	"ecommerce-app/domain/users/usecase"
+	"ecommerce-app/shared/cache"
 	"ecommerce-app/shared/middleware"
 
+	categoryHandlers "ecommerce-app/domain/categories/handlers"
+	categoryRepositories "ecommerce-app/domain/categories/repositories"
+	categoryUseCase "ecommerce-app/domain/categories/usecase"
+
 	productHandlers "ecommerce-app/domain/products/handlers"
 	productRepositories "ecommerce-app/domain/products/repositories"
 	productUseCase "ecommerce-app/domain/products/usecase"
 
+	inventoryHandlers "ecommerce-app/domain/inventory/handlers"
+	"ecommerce-app/domain/inventory/reconcile"
+	inventoryRepositories "ecommerce-app/domain/inventory/repositories"
+	inventoryUseCase "ecommerce-app/domain/inventory/usecase"
+
+	deadLetterHandlers "ecommerce-app/domain/deadletters/handlers"
+	deadLetterRepositories "ecommerce-app/domain/deadletters/repositories"
+	deadLetterUseCase "ecommerce-app/domain/deadletters/usecase"
+
 	orderHandlers "ecommerce-app/domain/orders/handlers"
 	orderRepositories "ecommerce-app/domain/orders/repositories"
 	orderUseCase "ecommerce-app/domain/orders/usecase"
 
 	inventory "ecommerce-app/workers/inventory"
 	notification "ecommerce-app/workers/notification"
+	outbox "ecommerce-app/workers/outbox"
+	reservation "ecommerce-app/workers/reservation"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -30,6 +57,19 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println(".env file not found — using system env")
 	}
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		if err := runReconcile(os.Args[2:]); err != nil {
+			log.Fatalf("reconcile: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "grant-admin" {
+		if err := runGrantAdmin(os.Args[2:]); err != nil {
+			log.Fatalf("grant-admin: %v", err)
+		}
+		return
+	}
+
 	db := config.GetDB()
 	redisClient := config.GetRedis()
 
@@ -37,22 +77,50 @@ func main() {
 	userUC := usecase.NewUserUseCase(userRepo)
 	userH := handlers.NewUserHandler(userUC)
 
+	productCache := cache.NewProductCache(redisClient)
+
+	categoryRepo := categoryRepositories.NewGormCategoryRepo(db)
+	categoryUC := categoryUseCase.NewCategoryUsecase(categoryRepo, productCache)
+	categoryH := categoryHandlers.NewCategoryHandler(categoryUC)
+
 	productRepo := productRepositories.NewGormProductRepo(db)
-	productUC := productUseCase.NewProductUsecase(productRepo, redisClient)
+	productUC := productUseCase.NewProductUsecase(productRepo, categoryRepo, productCache)
 	productH := productHandlers.NewProductHandler(productUC)
 
+	subscriptionRepo := productRepositories.NewGormSubscriptionRepo(db)
+	subscriptionUC := productUseCase.NewSubscriptionUsecase(subscriptionRepo, productRepo)
+	subscriptionH := productHandlers.NewSubscriptionHandler(subscriptionUC)
+
+	warehouseRepo := inventoryRepositories.NewGormWarehouseRepo(db)
+	warehouseUC := inventoryUseCase.NewWarehouseUsecase(warehouseRepo, productCache)
+	warehouseH := inventoryHandlers.NewWarehouseHandler(warehouseUC)
+
+	movementRepo := inventoryRepositories.NewGormMovementRepo(db)
+	movementUC := inventoryUseCase.NewMovementUsecase(movementRepo)
+	movementH := inventoryHandlers.NewMovementHandler(movementUC)
+
+	stockUC := inventoryUseCase.NewStockUsecase(db, productCache)
+	stockH := inventoryHandlers.NewStockHandler(stockUC)
+
 	orderRepo := orderRepositories.NewGormOrderRepo(db)
-	orderUC := orderUseCase.NewOrderUsecase(orderRepo, productRepo, redisClient)
+	orderUC := orderUseCase.NewOrderUsecase(db, orderRepo, productRepo, redisClient)
 	orderHandler := orderHandlers.NewOrderHandler(orderUC)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	if err := inventory.StartInventoryWorker(ctx, db, orderRepo, productRepo); err != nil {
+	deadLetterRepo := deadLetterRepositories.NewAMQPDeadLetterRepo()
+	deadLetterUC := deadLetterUseCase.NewDeadLetterUsecase(deadLetterRepo)
+	deadLetterH := deadLetterHandlers.NewDeadLetterHandler(deadLetterUC)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	inventoryDone, err := inventory.StartInventoryWorker(ctx, db, orderRepo, productRepo, productCache)
+	if err != nil {
 		log.Fatalf("failed to start inventory worker: %v", err)
 	}
-	if err := notification.StartNotificationWorker(ctx); err != nil {
+	if err := notification.StartNotificationWorker(ctx, subscriptionRepo); err != nil {
 		log.Fatalf("failed to start notification worker: %v", err)
 	}
+	reservation.StartReservationSweeper(ctx, db)
+	relayDone := outbox.StartOutboxRelay(ctx, db)
 
 	router := gin.Default()
 
@@ -65,16 +133,174 @@ func main() {
 		protected.GET("/profile", userH.GetProfile)
 		protected.PUT("/profile", userH.UpdateProfile)
 
+		protected.GET("/categories", categoryH.GetCategories)
+
 		protected.GET("/products", productH.GetProducts)
 		protected.GET("/products/:id", productH.GetProduct)
+		protected.POST("/products/:id/subscriptions", subscriptionH.Subscribe)
+		protected.DELETE("/products/:id/subscriptions", subscriptionH.Unsubscribe)
 
-		protected.POST("/orders", orderHandler.CreateOrder)
+		protected.POST("/orders", middleware.Idempotency(redisClient), orderHandler.CreateOrder)
+		protected.GET("/orders", orderHandler.ListOrders)
 		protected.GET("/orders/:id", orderHandler.GetOrder)
+		protected.POST("/orders/:id/cancel", orderHandler.CancelOrder)
+	}
+
+	staff := router.Group("/api/staff")
+	staff.Use(middleware.AuthMiddleware(), middleware.RequireRole(userEntities.RoleStaff, userEntities.RoleAdmin))
+	{
+		staff.GET("/warehouses", warehouseH.GetWarehouses)
+		staff.PUT("/warehouses/:id/stock", warehouseH.SetStockLevel)
+		staff.POST("/products/:id/restock", stockH.Restock)
+		staff.POST("/products/:id/adjustments", stockH.Adjust)
+		staff.POST("/orders/:id/status", orderHandler.UpdateStatus)
+		staff.GET("/products/:id/stock-levels", warehouseH.GetStockLevels)
+		staff.GET("/products/:id/movements", movementH.GetMovements)
+	}
+
+	admin := router.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(), middleware.RequireRole(userEntities.RoleAdmin))
+	{
+		admin.PUT("/users/:id/role", userH.UpdateRole)
+
+		admin.POST("/categories", categoryH.CreateCategory)
+		admin.POST("/warehouses", warehouseH.CreateWarehouse)
+
+		admin.POST("/products", productH.CreateProduct)
+		admin.PUT("/products/:id", productH.UpdateProduct)
+		admin.PATCH("/products/:id/stock", productH.AdjustStock)
+		admin.DELETE("/products/:id", productH.DeleteProduct)
+		admin.POST("/products/:id/variants", productH.CreateVariant)
+		admin.PUT("/products/:id/variants/:variantId", productH.UpdateVariant)
+		admin.PATCH("/products/:id/variants/:variantId/stock", productH.AdjustVariantStock)
+		admin.DELETE("/products/:id/variants/:variantId", productH.DeleteVariant)
+
+		admin.GET("/dead-letters", deadLetterH.Queues)
+		admin.GET("/dead-letters/:queue", deadLetterH.List)
+		admin.POST("/dead-letters/:queue/replay", deadLetterH.Replay)
+		admin.POST("/dead-letters/:queue/purge", deadLetterH.Purge)
 	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	router.Run(":" + port)
+	srv := &http.Server{Addr: ":" + port, Handler: router}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("shutting down: draining requests and workers")
+
+	timeout := 30 * time.Second
+	if n, _ := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS")); n > 0 {
+		timeout = time.Duration(n) * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server shutdown: %v", err)
+	}
+	select {
+	case <-inventoryDone:
+	case <-shutdownCtx.Done():
+		log.Println("inventory worker did not drain before the shutdown timeout")
+	}
+	select {
+	case <-relayDone:
+	case <-shutdownCtx.Done():
+		log.Println("outbox relay did not stop before the shutdown timeout")
+	}
+}
+
+// runGrantAdmin implements `grant-admin -email address`: it makes an account
+// that has already registered an administrator, which is how the first
+// administrator is created.
+func runGrantAdmin(args []string) error {
+	fs := flag.NewFlagSet("grant-admin", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the registered account to make an administrator")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+
+	user, err := usecase.NewUserUseCase(repositories.NewGormUserRepo(config.GetDB())).GrantAdmin(*email)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s (%s) is now an administrator\n", user.Email, user.ID)
+	return nil
+}
+
+// runReconcile implements `reconcile [-format table|json] [-product id]
+// [-apply] [-actor name]`: it reports how far recorded stock has drifted from
+// what the ledger and orders imply and, with -apply, corrects it.
+func runReconcile(args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	format := fs.String("format", "table", "output format: table or json")
+	productID := fs.String("product", "", "only reconcile this product ID")
+	apply := fs.Bool("apply", false, "correct drifted stock and record each correction in the ledger")
+	actor := fs.String("actor", "reconcile", "actor recorded on corrections")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	db := config.GetDB()
+	lines, err := reconcile.Report(db, *productID)
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	if *apply {
+		applied, err = reconcile.Apply(db, lines, *actor)
+		if err != nil {
+			return err
+		}
+		if applied > 0 {
+			if err := cache.NewProductCache(config.GetRedis()).Invalidate(context.Background()); err != nil {
+				log.Printf("reconcile: cache invalidation failed: %v", err)
+			}
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Lines   []reconcile.Line `json:"lines"`
+			Applied int              `json:"applied"`
+		}{lines, applied})
+	}
+
+	var drifted, untracked int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRODUCT\tVARIANT\tNAME\tRECORDED\tEXPECTED\tDRIFT\tSTATUS")
+	for _, l := range lines {
+		switch l.Status {
+		case reconcile.StatusDrift:
+			drifted++
+		case reconcile.StatusUntracked:
+			untracked++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%+d\t%s\n", l.ProductID, l.VariantID, l.Name, l.Recorded, l.Expected, l.Drift, l.Status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d checked, %d drifted, %d untracked", len(lines), drifted, untracked)
+	if *apply {
+		fmt.Printf(", %d corrections applied", applied)
+	}
+	fmt.Println()
+	return nil
 }
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const productsVersionKey = "products:version"
+
+// ProductCache stores product listings under keys tagged with a catalog
+// version. Invalidate bumps the version, which orphans every listing cached
+// before it; the orphans then age out through their TTL.
+type ProductCache struct {
+	client *redis.Client
+}
+
+func NewProductCache(client *redis.Client) *ProductCache {
+	return &ProductCache{client}
+}
+
+func (c *ProductCache) ListKey(ctx context.Context, parts ...string) (string, error) {
+	v, err := c.client.Get(ctx, productsVersionKey).Result()
+	if err == redis.Nil {
+		v = "0"
+	} else if err != nil {
+		return "", err
+	}
+	return "products:v" + v + ":" + strings.Join(parts, ":"), nil
+}
+
+func (c *ProductCache) Get(ctx context.Context, key string, dest interface{}) bool {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, dest) == nil
+}
+
+func (c *ProductCache) Set(ctx context.Context, key string, v interface{}, ttl time.Duration) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	c.client.Set(ctx, key, bytes, ttl)
+}
+
+func (c *ProductCache) Invalidate(ctx context.Context) error {
+	return c.client.Incr(ctx, productsVersionKey).Err()
+}
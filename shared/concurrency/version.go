@@ -0,0 +1,57 @@
+// Package concurrency implements optimistic locking on rows that carry a
+// version column.
+package concurrency
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConflictError reports that a row changed after it was read, so writing the
+// caller's copy would overwrite someone else's change.
+type ConflictError struct {
+	Entity string
+	ID     string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s was modified by another request; reload it and try again", e.Entity, e.ID)
+}
+
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
+
+// Update writes every column of model, the entity with primary key id, only
+// if its row is still at *version, and moves *version on by one. When the
+// row has moved on, or is gone, nothing is written and a *ConflictError is
+// returned. Associations are not saved.
+func Update(tx *gorm.DB, entity, id string, model interface{}, version *int) error {
+	old := *version
+	*version = old + 1
+	res := tx.Model(model).Where("version = ?", old).
+		Select("*").Omit(clause.Associations, "created_at").
+		Updates(model)
+	if res.Error != nil {
+		*version = old
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		*version = old
+		return &ConflictError{Entity: entity, ID: id}
+	}
+	return nil
+}
+
+// Check returns a *ConflictError when the caller said which version it
+// edited and the row has moved on since.
+func Check(entity, id string, expected *int, current int) error {
+	if expected != nil && *expected != current {
+		return &ConflictError{Entity: entity, ID: id}
+	}
+	return nil
+}
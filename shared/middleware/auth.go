@@ -9,6 +9,7 @@ import (
 )
 
 const ctxUserID = "user_id"
+const ctxRole = "role"
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -32,6 +33,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		c.Set(ctxUserID, claims.UserID)
+		c.Set(ctxRole, claims.Role)
 		c.Next()
 	}
 }
@@ -44,3 +46,40 @@ func GetUserID(c *gin.Context) (string, bool) {
 	s, ok := v.(string)
 	return s, ok
 }
+
+// Actor identifies the authenticated caller in audit records such as the stock
+// ledger. It is empty when the request is not authenticated.
+func Actor(c *gin.Context) string {
+	uid, _ := GetUserID(c)
+	return uid
+}
+
+func GetRole(c *gin.Context) (string, bool) {
+	v, ok := c.Get(ctxRole)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// RequireRole must run after AuthMiddleware; it rejects callers whose token
+// role is not one of roles. The role is the one the token was issued with,
+// so a role change, demotions included, only applies to tokens issued after
+// it; existing tokens keep the old role until they expire.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := GetRole(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	}
+}
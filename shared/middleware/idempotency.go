@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader is set on responses served from a stored result.
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLockTTL bounds how long a key stays claimed by a request that
+// never finishes, for example because the server died mid-request.
+const idempotencyLockTTL = time.Minute
+
+// idempotentResult is what is stored under a key: the fingerprint of the
+// request that claimed it and, once it has finished, its response.
+type idempotentResult struct {
+	Fingerprint string `json:"fingerprint"`
+	Done        bool   `json:"done"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency must run after AuthMiddleware. A request carrying an
+// Idempotency-Key header is run once per user and key: repeats get the
+// stored response back, a repeat with a different body is rejected with 422
+// and one that arrives while the first is still running gets 409. Only
+// successes and 4xx responses that would come out the same on a retry are
+// stored; server errors and transient refusals such as 409 release the key,
+// so the client can retry them with the same key. JSON bodies are compared
+// after canonicalizing, so whitespace and key order do not count as a change.
+// Results are kept for IDEMPOTENCY_TTL_HOURS (default 24).
+func Idempotency(client *redis.Client) gin.HandlerFunc {
+	ttl := idempotencyTTL()
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			return
+		}
+		uid, ok := GetUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.FullPath()+"\n"), canonicalJSON(body)...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		ctx := c.Request.Context()
+		redisKey := "idempotency:" + uid + ":" + key
+		claim, _ := json.Marshal(idempotentResult{Fingerprint: fingerprint})
+		claimed, err := client.SetNX(ctx, redisKey, claim, idempotencyLockTTL).Result()
+		if err != nil {
+			log.Printf("idempotency: claim %s failed: %v", redisKey, err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "idempotency store unavailable"})
+			return
+		}
+		if !claimed {
+			replay(c, client, redisKey, fingerprint)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		// The request's context may be cancelled by now, so the result is
+		// written with a fresh one.
+		status := rec.Status()
+		if !storable(status) {
+			if err := client.Del(context.Background(), redisKey).Err(); err != nil {
+				log.Printf("idempotency: release %s failed: %v", redisKey, err)
+			}
+			return
+		}
+		result, _ := json.Marshal(idempotentResult{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		})
+		if err := client.Set(context.Background(), redisKey, result, ttl).Err(); err != nil {
+			log.Printf("idempotency: store %s failed: %v", redisKey, err)
+		}
+	}
+}
+
+// storable reports whether a response with status is final for its request.
+// Conflicts, timeouts and rate limits depend on the moment, for example stock
+// running out or a concurrent update, so a retry may well succeed.
+func storable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 200 && status < http.StatusInternalServerError
+}
+
+// canonicalJSON re-encodes a JSON body with sorted keys and no insignificant
+// whitespace. Anything that is not valid JSON is returned unchanged.
+func canonicalJSON(body []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// replay answers a request whose key is already taken.
+func replay(c *gin.Context, client *redis.Client, redisKey, fingerprint string) {
+	data, err := client.Get(c.Request.Context(), redisKey).Bytes()
+	if err == redis.Nil {
+		// The first request failed and released the key in between.
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress; retry"})
+		return
+	}
+	if err != nil {
+		log.Printf("idempotency: read %s failed: %v", redisKey, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "idempotency store unavailable"})
+		return
+	}
+	var stored idempotentResult
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Printf("idempotency: corrupt record %s: %v", redisKey, err)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "idempotency store unavailable"})
+		return
+	}
+	switch {
+	case stored.Fingerprint != fingerprint:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "idempotency key was already used with a different request"})
+	case !stored.Done:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is in progress; retry"})
+	default:
+		c.Header(IdempotentReplayHeader, "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+	}
+}
+
+func idempotencyTTL() time.Duration {
+	hours := 24
+	if v := os.Getenv("IDEMPOTENCY_TTL_HOURS"); v != "" {
+		if parsed, _ := strconv.Atoi(v); parsed > 0 {
+			hours = parsed
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
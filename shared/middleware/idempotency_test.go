@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"whitespace", `{"a":1,"b":[1,2]}`, "{ \"a\": 1,\n  \"b\": [1, 2] }\n", true},
+		{"key order", `{"a":1,"b":{"c":2,"d":3}}`, `{"b":{"d":3,"c":2},"a":1}`, true},
+		{"numbers keep their spelling", `{"price":2.50}`, `{"price":2.5}`, false},
+		{"different values", `{"quantity":1}`, `{"quantity":2}`, false},
+		{"array order counts", `[1,2]`, `[2,1]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := string(canonicalJSON([]byte(tt.a))), string(canonicalJSON([]byte(tt.b)))
+			if (a == b) != tt.same {
+				t.Fatalf("canonicalJSON gave %s and %s; same = %v, want %v", a, b, a == b, tt.same)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSONLeavesInvalidBodies(t *testing.T) {
+	for _, body := range []string{"", "not json", `{"a":1}}`, `{"a":1} {"a":2}`} {
+		if got := string(canonicalJSON([]byte(body))); got != body {
+			t.Errorf("canonicalJSON(%q) = %q, want it unchanged", body, got)
+		}
+	}
+}
+
+func TestStorable(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusCreated, true},
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusConflict, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		if got := storable(tt.status); got != tt.want {
+			t.Errorf("storable(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package pagination holds the limit checks and errors shared by the
+// cursor-paginated list endpoints.
+package pagination
+
+import "errors"
+
+// MaxLimit is the largest page any list endpoint returns.
+const MaxLimit = 100
+
+var (
+	ErrInvalidLimit  = errors.New("limit must be between 1 and 100")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Limit returns the page size to use: requested, or def when the caller left
+// it unset. Anything outside 1..MaxLimit is rejected with ErrInvalidLimit.
+func Limit(requested, def int) (int, error) {
+	if requested == 0 {
+		requested = def
+	}
+	if requested < 1 || requested > MaxLimit {
+		return 0, ErrInvalidLimit
+	}
+	return requested, nil
+}
@@ -0,0 +1,109 @@
+// Package retry re-runs database work that failed for transient reasons.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"ecommerce-app/shared/concurrency"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes worth retrying: the transaction lost a race rather
+// than being wrong.
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+	codeLockNotAvailable     = "55P03"
+)
+
+// Retryable reports whether err is transient: a serialization failure,
+// deadlock or lock timeout, a version conflict, or a lost connection.
+// Anything else is permanent and will fail the same way again. Work retried
+// after a version conflict must reload what it read.
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if concurrency.IsConflict(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case codeSerializationFailure, codeDeadlockDetected, codeLockNotAvailable:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return pgconn.SafeToRetry(err)
+}
+
+// Policy bounds a retry loop. Attempt n (from 0) waits BaseDelay * 2^n,
+// capped at MaxDelay, with up to half of that added as jitter.
+type Policy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// PolicyFromEnv reads <prefix>_RETRY_ATTEMPTS, <prefix>_RETRY_BASE_MS and
+// <prefix>_RETRY_MAX_MS, defaulting to 5 attempts from 100ms up to 5s.
+func PolicyFromEnv(prefix string) Policy {
+	p := Policy{Attempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+	if n := envInt(prefix + "_RETRY_ATTEMPTS"); n > 0 {
+		p.Attempts = n
+	}
+	if n := envInt(prefix + "_RETRY_BASE_MS"); n > 0 {
+		p.BaseDelay = time.Duration(n) * time.Millisecond
+	}
+	if n := envInt(prefix + "_RETRY_MAX_MS"); n > 0 {
+		p.MaxDelay = time.Duration(n) * time.Millisecond
+	}
+	return p
+}
+
+// Do runs fn until it succeeds, fails permanently, the attempts run out or
+// ctx is done, and returns fn's last error.
+func Do(ctx context.Context, p Policy, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < max(p.Attempts, 1); attempt++ {
+		if err = fn(); err == nil || !Retryable(err) {
+			return err
+		}
+		if attempt == p.Attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(p.Delay(attempt)):
+		}
+	}
+	return err
+}
+
+// Delay is how long to wait before retrying after failed attempt number
+// attempt, counting from 0.
+func (p Policy) Delay(attempt int) time.Duration {
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+func envInt(key string) int {
+	n, _ := strconv.Atoi(os.Getenv(key))
+	return n
+}
@@ -10,11 +10,12 @@ import (
 
 type Claims struct {
 	UserID string `json:"user_id"`
+	Role   string `json:"role"`
 	jwt.RegisteredClaims
 }
 
 
-func GenerateToken(userID string, ttl time.Duration) (string, time.Time, error) {
+func GenerateToken(userID, role string, ttl time.Duration) (string, time.Time, error) {
 	if s := os.Getenv("JWT_EXPIRY_MINUTES"); s != "" {
 		if v, err := strconv.Atoi(s); err == nil && v > 0 {
 			ttl = time.Duration(v) * time.Minute
@@ -24,6 +25,7 @@ func GenerateToken(userID string, ttl time.Duration) (string, time.Time, error)
 	exp := time.Now().Add(ttl)
 	claims := Claims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(exp),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -3,25 +3,62 @@ package inventory
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"ecommerce-app/events"
+	"ecommerce-app/domain/inventory/allocation"
+	inventoryEntities "ecommerce-app/domain/inventory/entities"
+	"ecommerce-app/domain/inventory/ledger"
+	inventoryRepo "ecommerce-app/domain/inventory/repositories"
 	prodEntities "ecommerce-app/domain/products/entities"
 	orderEntities "ecommerce-app/domain/orders/entities"
 	orderRepo "ecommerce-app/domain/orders/repositories"
+	outboxRepo "ecommerce-app/domain/outbox/repositories"
 	productRepo "ecommerce-app/domain/products/repositories"
 	"ecommerce-app/config"
+	"ecommerce-app/shared/cache"
+	"ecommerce-app/shared/concurrency"
+	"ecommerce-app/shared/retry"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-func StartInventoryWorker(ctx context.Context, db *gorm.DB, orderRepository orderRepo.OrderRepository, productRepository productRepo.ProductRepository) error {
+// StartInventoryWorker consumes placed orders and restock events. Deliveries
+// are spread over a pool of INVENTORY_WORKERS goroutines (default 4), sharded
+// by one product each: orders by their lowest product ID, restocks by the
+// restocked product. Work sharing that product runs in order on one
+// goroutine, but an order is not ordered against work on its other products,
+// which may run at the same time on other goroutines; the row locks taken by
+// LockItems, always in ID order, keep those correct. At most
+// INVENTORY_PREFETCH deliveries (default twice the pool size) are unacked at
+// once. The returned channel is closed once ctx is done and in-flight work
+// has drained.
+func StartInventoryWorker(ctx context.Context, db *gorm.DB, orderRepository orderRepo.OrderRepository, productRepository productRepo.ProductRepository, productCache *cache.ProductCache) (<-chan struct{}, error) {
+	strategy, err := allocation.New(os.Getenv("INVENTORY_ALLOCATION_STRATEGY"))
+	if err != nil {
+		return nil, err
+	}
+	retryPolicy := retry.PolicyFromEnv("INVENTORY")
+
+	workers := 4
+	if n, _ := strconv.Atoi(os.Getenv("INVENTORY_WORKERS")); n > 0 {
+		workers = n
+	}
+	prefetch := workers * 2
+	if n, _ := strconv.Atoi(os.Getenv("INVENTORY_PREFETCH")); n > 0 {
+		prefetch = n
+	}
+
 	ch, err := config.NewChannel()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	exchange := os.Getenv("RABBITMQ_EXCHANGE")
@@ -36,13 +73,24 @@ func StartInventoryWorker(ctx context.Context, db *gorm.DB, orderRepository orde
 	if placedQueue == "" {
 		placedQueue = "order_placed_queue"
 	}
+	restockedQueue := os.Getenv("RABBITMQ_RESTOCKED_QUEUE")
+	if restockedQueue == "" {
+		restockedQueue = "inventory_restocked_queue"
+	}
 
 	if err := config.EnsureDirectExchange(ch, exchange); err != nil {
-		return err
+		return nil, err
 	}
-	_, err = config.DeclareQuorumQueue(ch, placedQueue, exchange, placedRoutingKey)
+	_, err = config.DeclareWorkQueue(ch, placedQueue, exchange, placedRoutingKey)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	_, err = config.DeclareWorkQueue(ch, restockedQueue, exchange, events.RestockedRoutingKey())
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return nil, err
 	}
 
 	msgs, err := ch.Consume(
@@ -55,128 +103,601 @@ func StartInventoryWorker(ctx context.Context, db *gorm.DB, orderRepository orde
 		nil,
 	)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	restockedMsgs, err := ch.Consume(restockedQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("Inventory worker: consuming", placedQueue)
+	log.Printf("Inventory worker: consuming %s and %s with %d workers, prefetch %d", placedQueue, restockedQueue, workers, prefetch)
+
+	p := newPool(workers, prefetch, func(j job) {
+		start := time.Now()
+		var stockChanged bool
+		err := retry.Do(ctx, retryPolicy, func() error {
+			changed, err := j.run()
+			stockChanged = stockChanged || changed
+			return err
+		})
+		if err != nil && ctx.Err() != nil && retry.Retryable(err) {
+			// Shutdown cut the retries short; the delivery has not used
+			// them up, so it goes back on the queue as it is.
+			log.Printf("inventory: %s interrupted by shutdown, requeueing: %v", j.name, err)
+			_ = j.d.Nack(false, true)
+			return
+		}
+		if err != nil {
+			log.Printf("inventory: processing error for %s: %v", j.name, err)
+			config.Fail(j.d, exchange, j.queue, err.Error(), retry.Retryable(err))
+			return
+		}
+		if stockChanged {
+			if err := productCache.Invalidate(context.Background()); err != nil {
+				log.Printf("inventory: product cache invalidation failed: %v", err)
+			}
+		}
+
+		j.d.Ack(false)
+		log.Printf("inventory: processed %s in %s", j.name, time.Since(start))
+	})
 
+	swept := startBackorderSweep(ctx, db, strategy, retryPolicy, productCache)
+
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+		defer func() {
+			// Finish what was handed to the pool, then close the channel,
+			// which returns anything still prefetched to the queue.
+			<-swept
+			p.drain()
+			_ = ch.Close()
+			log.Println("Inventory worker: drained")
+		}()
+
 		for {
 			select {
 			case <-ctx.Done():
-				_ = ch.Close()
 				return
 			case d, ok := <-msgs:
 				if !ok {
 					log.Println("Inventory worker: delivery channel closed")
 					return
 				}
-				start := time.Now()
 
 				var payload events.OrderPlacedPayload
 				if err := json.Unmarshal(d.Body, &payload); err != nil {
-					log.Printf("inventory: invalid message, nack & discard: %v", err)
-					d.Nack(false, false)
+					log.Printf("inventory: invalid message, dead-lettering: %v", err)
+					config.Fail(d, exchange, placedQueue, "invalid payload: "+err.Error(), false)
 					continue
 				}
+				p.submit(ctx, orderShardKey(&payload), job{
+					d:     d,
+					queue: placedQueue,
+					name:  "order " + payload.OrderID,
+					run: func() (bool, error) {
+						return processOrder(db, &payload, orderRepository, strategy)
+					},
+				})
+			case d, ok := <-restockedMsgs:
+				if !ok {
+					log.Println("Inventory worker: restocked channel closed")
+					return
+				}
 
-				if err := processOrder(db, &payload, orderRepository); err != nil {
-					log.Printf("inventory: processing error for order %s: %v", payload.OrderID, err)
-					d.Nack(false, true) 
+				var payload events.RestockedPayload
+				if err := json.Unmarshal(d.Body, &payload); err != nil {
+					log.Printf("inventory: invalid restocked message, dead-lettering: %v", err)
+					config.Fail(d, exchange, restockedQueue, "invalid payload: "+err.Error(), false)
 					continue
 				}
-
-				d.Ack(false)
-				log.Printf("inventory: processed order %s in %s", payload.OrderID, time.Since(start))
+				p.submit(ctx, payload.ProductID, job{
+					d:     d,
+					queue: restockedQueue,
+					name:  "backorders of product " + payload.ProductID,
+					run: func() (bool, error) {
+						return fillBackorders(db, payload.ProductID, payload.VariantID, strategy)
+					},
+				})
 			}
 		}
 	}()
 
-	return nil
+	return done, nil
+}
+
+// orderShardKey is the lowest product ID in the order, so orders sharing
+// their lowest product are handled in order by the same goroutine. Nothing
+// more is promised: orders {A, B} and {B} can land on different goroutines
+// and then serialize on B's row lock instead.
+func orderShardKey(p *events.OrderPlacedPayload) string {
+	key := ""
+	for _, it := range p.Items {
+		if key == "" || it.ProductID < key {
+			key = it.ProductID
+		}
+	}
+	return key
 }
 
-func processOrder(db *gorm.DB, p *events.OrderPlacedPayload, orderRepository orderRepo.OrderRepository) error {
-	return db.Transaction(func(tx *gorm.DB) error {
-		order, err := orderRepository.FindByID(p.OrderID)
+const workerActor = "inventory-worker"
+
+var errOutOfStock = errors.New("out of stock")
+
+func processOrder(db *gorm.DB, p *events.OrderPlacedPayload, orderRepository orderRepo.OrderRepository, strategy allocation.Strategy) (bool, error) {
+	order, err := orderRepository.FindByID(p.OrderID)
+	if err != nil {
+		return false, err
+	}
+
+	if !order.Status.AwaitingAllocation() {
+		return false, nil
+	}
+
+	dest := shippingDestination(order)
+
+	var stockChanged bool
+	var reason string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		stockChanged = false
+		items := make([]inventoryRepo.StockItem, 0, len(order.Items))
+		for _, it := range order.Items {
+			items = append(items, inventoryRepo.StockItem{ProductID: it.ProductID, VariantID: it.VariantID})
+		}
+		if err := inventoryRepo.LockItems(tx, items); err != nil {
+			return err
+		}
+		for i := range order.Items {
+			item := &order.Items[i]
+			before := item.FulfilledQuantity
+			if err := allocateItem(tx, order.ID, item, strategy, dest); err != nil {
+				return err
+			}
+			if item.FulfilledQuantity > before {
+				stockChanged = true
+			}
+		}
+		for _, it := range order.Items {
+			if it.Status == orderEntities.ItemCancelled {
+				order.Total -= it.UnitPrice * float64(it.Quantity)
+			}
+		}
+		status := settledStatus(order)
+		reason = resultReason(status, order)
+		settle := inventoryRepo.CommitReservations
+		if status == orderEntities.StatusCancelled {
+			settle = inventoryRepo.ReleaseReservations
+		}
+		if err := settle(tx, order.ID); err != nil {
+			return err
+		}
+		if err := orderRepo.Transition(tx, order, status, workerActor, reason); err != nil {
+			return err
+		}
+		if err := concurrency.Update(tx, "order", order.ID, order, &order.Version); err != nil {
+			return err
+		}
+		return enqueueOrderResult(tx, order, status, reason)
+	})
+	if errors.Is(err, errOutOfStock) {
+		// The allocation transaction has rolled back, so no line keeps stock.
+		for i := range order.Items {
+			order.Items[i].Status = orderEntities.ItemCancelled
+			order.Items[i].FulfilledQuantity = 0
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := inventoryRepo.ReleaseReservations(tx, order.ID); err != nil {
+				return err
+			}
+			if err := tx.Model(&orderEntities.OrderItem{}).Where("order_id = ?", order.ID).
+				Updates(map[string]interface{}{"status": orderEntities.ItemCancelled, "fulfilled_quantity": 0}).Error; err != nil {
+				return err
+			}
+			if err := orderRepo.Transition(tx, order, orderEntities.StatusCancelled, workerActor, "out_of_stock"); err != nil {
+				return err
+			}
+			if err := orderRepository.WithTx(tx).Update(order); err != nil {
+				return err
+			}
+			return enqueueOrderResult(tx, order, orderEntities.StatusCancelled, "out_of_stock")
+		})
 		if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return stockChanged, nil
+}
+
+// settledStatus derives the status of an allocated order from its lines.
+func settledStatus(order *orderEntities.Order) orderEntities.OrderStatus {
+	var open, backordered int
+	for _, it := range order.Items {
+		switch it.Status {
+		case orderEntities.ItemCancelled:
+		case orderEntities.ItemBackordered:
+			open++
+			backordered++
+		default:
+			open++
+		}
+	}
+	switch {
+	case open == 0:
+		return orderEntities.StatusCancelled
+	case backordered > 0:
+		return orderEntities.StatusBackordered
+	}
+	return orderEntities.StatusConfirmed
+}
+
+// resultReason explains an order settling at status.
+func resultReason(status orderEntities.OrderStatus, order *orderEntities.Order) string {
+	if status == orderEntities.StatusCancelled {
+		return "out_of_stock"
+	}
+	if status == orderEntities.StatusBackordered {
+		return "backordered"
+	}
+	for _, it := range order.Items {
+		if it.Status == orderEntities.ItemCancelled {
+			return "partially_cancelled"
+		}
+	}
+	return ""
+}
+
+func shippingDestination(order *orderEntities.Order) *allocation.Location {
+	if order.ShippingLatitude == nil || order.ShippingLongitude == nil {
+		return nil
+	}
+	return &allocation.Location{Latitude: *order.ShippingLatitude, Longitude: *order.ShippingLongitude}
+}
+
+// allocateItem takes the outstanding quantity of one order line out of stock,
+// or as much of it as the line's fulfilment policy allows. Rows are locked
+// product, then variant, then warehouse levels. Stock held by other orders'
+// active reservations is off limits. Items stocked in warehouses are split
+// across them by strategy and the chosen allocations are recorded; items
+// without warehouse levels only draw down the product/variant totals. Every
+// unit taken is written to the stock ledger against the order.
+//
+// A short cancel_all line fails with errOutOfStock; a short partial line is
+// cancelled untouched; a short backorder line takes what there is and waits
+// for the rest. The line's status and fulfilled quantity are saved.
+func allocateItem(tx *gorm.DB, orderID string, item *orderEntities.OrderItem, strategy allocation.Strategy, dest *allocation.Location) error {
+	var prod prodEntities.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", item.ProductID).Error; err != nil {
+		return err
+	}
+	available := prod.Stock
+	var variant prodEntities.ProductVariant
+	if item.VariantID != "" {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ? AND product_id = ?", item.VariantID, item.ProductID).Error; err != nil {
+			return err
+		}
+		available = variant.Stock
+	}
+	held, err := inventoryRepo.HeldQuantity(tx, item.ProductID, item.VariantID, orderID)
+	if err != nil {
+		return err
+	}
+	levels, err := inventoryRepo.LockStockLevels(tx, item.ProductID, item.VariantID)
+	if err != nil {
+		return err
+	}
+
+	want := item.Quantity - item.FulfilledQuantity
+	take := min(want, max(available-held, 0))
+	if len(levels) > 0 {
+		var inWarehouses int
+		for _, l := range levels {
+			inWarehouses += max(l.Quantity, 0)
+		}
+		take = min(take, inWarehouses)
+	}
+	if take < want {
+		switch item.FulfilmentPolicy {
+		case orderEntities.FulfilmentPartial:
+			take = 0
+		case orderEntities.FulfilmentBackorder:
+		default:
+			return errOutOfStock
+		}
+	}
+
+	if take > 0 {
+		if err := takeStock(tx, orderID, item, take, &prod, &variant, levels, strategy, dest); err != nil {
 			return err
 		}
+	}
 
-		if order.Status != "PENDING" {
-			return nil
+	item.FulfilledQuantity += take
+	switch {
+	case item.FulfilledQuantity == item.Quantity:
+		item.Status = orderEntities.ItemAllocated
+	case item.FulfilmentPolicy == orderEntities.FulfilmentBackorder:
+		item.Status = orderEntities.ItemBackordered
+	default:
+		item.Status = orderEntities.ItemCancelled
+	}
+	return tx.Model(&orderEntities.OrderItem{}).Where("id = ?", item.ID).
+		Updates(map[string]interface{}{"status": item.Status, "fulfilled_quantity": item.FulfilledQuantity}).Error
+}
+
+// takeStock draws quantity units of an order line from the locked product,
+// variant and warehouse levels, which allocateItem has checked can cover it.
+func takeStock(tx *gorm.DB, orderID string, item *orderEntities.OrderItem, quantity int, prod *prodEntities.Product, variant *prodEntities.ProductVariant, levels []inventoryEntities.StockLevel, strategy allocation.Strategy, dest *allocation.Location) error {
+	entry := ledger.Entry{Reason: inventoryEntities.MovementOrder, ReferenceID: orderID, Actor: workerActor}
+	if len(levels) > 0 {
+		sources := make([]allocation.Source, 0, len(levels))
+		byWarehouse := make(map[string]*inventoryEntities.StockLevel, len(levels))
+		for i := range levels {
+			l := &levels[i]
+			byWarehouse[l.WarehouseID] = l
+			src := allocation.Source{WarehouseID: l.WarehouseID, Available: l.Quantity}
+			if l.Warehouse != nil {
+				src.Priority = l.Warehouse.Priority
+				src.Latitude = l.Warehouse.Latitude
+				src.Longitude = l.Warehouse.Longitude
+			}
+			sources = append(sources, src)
 		}
 
-		for _, item := range p.Items {
-			var prod prodEntities.Product
-			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&prod, "id = ?", item.ProductID).Error; err != nil {
+		allocs := strategy.Allocate(quantity, sources, dest)
+		if allocs == nil {
+			return errOutOfStock
+		}
+		for _, a := range allocs {
+			l := byWarehouse[a.WarehouseID]
+			l.Quantity = l.Quantity - a.Quantity
+			if err := tx.Model(l).Update("quantity", l.Quantity).Error; err != nil {
 				return err
 			}
-			if prod.Stock < item.Quantity {
-				order.Status = "CANCELLED"
-				if err := tx.Save(order).Error; err != nil {
-					return err
-				}
-				go publishOrderResult(order, "CANCELLED", "out_of_stock")
-				return nil 
+			if err := ledger.Record(tx, item.ProductID, item.VariantID, a.WarehouseID, -a.Quantity, entry); err != nil {
+				return err
 			}
-			prod.Stock = prod.Stock - item.Quantity
-			if err := tx.Save(&prod).Error; err != nil {
+			if err := tx.Create(&orderEntities.OrderItemAllocation{
+				ID:          uuid.NewString(),
+				OrderItemID: item.ID,
+				WarehouseID: a.WarehouseID,
+				Quantity:    a.Quantity,
+			}).Error; err != nil {
 				return err
 			}
 		}
+	} else if err := ledger.Record(tx, item.ProductID, item.VariantID, "", -quantity, entry); err != nil {
+		return err
+	}
 
-		order.Status = "CONFIRMED"
-		if err := tx.Save(order).Error; err != nil {
+	if item.VariantID != "" {
+		variant.Stock = variant.Stock - quantity
+		if err := tx.Save(variant).Error; err != nil {
 			return err
 		}
+	}
+	prod.Stock = prod.Stock - quantity
+	if err := concurrency.Update(tx, "product", prod.ID, prod, &prod.Version); err != nil {
+		return err
+	}
+	return productRepo.TrackLowStock(tx, prod)
+}
 
-		go publishOrderResult(order, "CONFIRMED", "")
-		return nil
-	})
+// fillBackorders hands newly arrived stock of one product or variant to
+// backordered lines, oldest order first, until the stock runs out.
+func fillBackorders(db *gorm.DB, productID, variantID string, strategy allocation.Strategy) (bool, error) {
+	var items []orderEntities.OrderItem
+	err := db.Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.product_id = ? AND order_items.variant_id = ? AND order_items.status = ?",
+			productID, variantID, orderEntities.ItemBackordered).
+		Order("orders.created_at, order_items.id").
+		Find(&items).Error
+	if err != nil {
+		return false, err
+	}
+
+	stockChanged := false
+	for _, it := range items {
+		taken, err := fillBackorder(db, it.OrderID, it.ID, strategy)
+		if err != nil {
+			return stockChanged, err
+		}
+		if !taken {
+			break
+		}
+		stockChanged = true
+	}
+	return stockChanged, nil
 }
 
-func publishOrderResult(order *orderEntities.Order, status, reason string) {
-	ch, err := config.NewChannel()
+// startBackorderSweep tries to fill every waiting backorder every
+// BACKORDER_SWEEP_SECONDS (default 60). Restock events fill backorders as
+// soon as stock arrives, but stock also comes back without one, when holds
+// expire or orders are cancelled, and an event can be lost; the sweep picks
+// those up. The returned channel is closed once the sweep has stopped.
+func startBackorderSweep(ctx context.Context, db *gorm.DB, strategy allocation.Strategy, policy retry.Policy, productCache *cache.ProductCache) <-chan struct{} {
+	interval := time.Minute
+	if n, _ := strconv.Atoi(os.Getenv("BACKORDER_SWEEP_SECONDS")); n > 0 {
+		interval = time.Duration(n) * time.Second
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := sweepBackorders(ctx, db, strategy, policy)
+				if err != nil {
+					log.Printf("inventory: backorder sweep failed: %v", err)
+				}
+				if changed {
+					if err := productCache.Invalidate(context.Background()); err != nil {
+						log.Printf("inventory: product cache invalidation failed: %v", err)
+					}
+				}
+			}
+		}
+	}()
+	return stopped
+}
+
+// sweepBackorders runs fillBackorders for every product and variant that has
+// a line waiting on it.
+func sweepBackorders(ctx context.Context, db *gorm.DB, strategy allocation.Strategy, policy retry.Policy) (bool, error) {
+	var waiting []struct {
+		ProductID string
+		VariantID string
+	}
+	err := db.Table("order_items").
+		Select("DISTINCT order_items.product_id, order_items.variant_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("order_items.status = ? AND orders.status = ?", orderEntities.ItemBackordered, orderEntities.StatusBackordered).
+		Order("order_items.product_id, order_items.variant_id").
+		Scan(&waiting).Error
 	if err != nil {
-		log.Printf("inventory: publish channel error: %v", err)
-		return
+		return false, err
 	}
-	defer ch.Close()
 
-	exchange := os.Getenv("RABBITMQ_EXCHANGE")
-	if exchange == "" {
-		exchange = "orders_direct"
+	stockChanged := false
+	for _, w := range waiting {
+		if ctx.Err() != nil {
+			break
+		}
+		err := retry.Do(ctx, policy, func() error {
+			changed, err := fillBackorders(db, w.ProductID, w.VariantID, strategy)
+			stockChanged = stockChanged || changed
+			return err
+		})
+		if err != nil {
+			return stockChanged, fmt.Errorf("product %s: %w", w.ProductID, err)
+		}
 	}
-	confirmedRoutingKey := os.Getenv("RABBITMQ_CONFIRM_ROUTING_KEY")
-	if confirmedRoutingKey == "" {
-		confirmedRoutingKey = "order.confirmed"
+	return stockChanged, nil
+}
+
+// fillBackorder allocates what it can to one backordered line and confirms
+// the order once none of its lines are waiting. The order row is locked first
+// so two fills of the same order cannot interleave.
+func fillBackorder(db *gorm.DB, orderID, itemID string, strategy allocation.Strategy) (bool, error) {
+	var order orderEntities.Order
+	taken := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		taken = false
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", orderID).Error; err != nil {
+			return err
+		}
+		if order.Status != orderEntities.StatusBackordered {
+			return nil
+		}
+		if err := tx.Where("order_id = ?", orderID).Find(&order.Items).Error; err != nil {
+			return err
+		}
+
+		var item *orderEntities.OrderItem
+		for i := range order.Items {
+			if order.Items[i].ID == itemID {
+				item = &order.Items[i]
+			}
+		}
+		if item == nil || item.Status != orderEntities.ItemBackordered {
+			return nil
+		}
+
+		before := item.FulfilledQuantity
+		if err := allocateItem(tx, order.ID, item, strategy, shippingDestination(&order)); err != nil {
+			return err
+		}
+		if item.FulfilledQuantity == before {
+			return nil
+		}
+		taken = true
+
+		// The line changed even if the order is still waiting, so the version
+		// moves on and optimistic writers holding the old lines are refused.
+		confirmed := settledStatus(&order) == orderEntities.StatusConfirmed
+		if confirmed {
+			if err := orderRepo.Transition(tx, &order, orderEntities.StatusConfirmed, workerActor, "backorder_filled"); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&order).Omit(clause.Associations).Updates(map[string]interface{}{"status": order.Status, "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		if !confirmed {
+			return nil
+		}
+		return enqueueOrderResult(tx, &order, orderEntities.StatusConfirmed, "backorder_filled")
+	})
+	if err != nil {
+		return false, err
+	}
+	return taken, nil
+}
+
+// enqueueOrderResult writes the order's result event to the outbox inside tx,
+// so it goes out if and only if the status change commits. Cancellations go
+// to the failed routing key, backorders to the backordered one and
+// confirmations to the confirmed one.
+func enqueueOrderResult(tx *gorm.DB, order *orderEntities.Order, status orderEntities.OrderStatus, reason string) error {
+	if status == orderEntities.StatusBackordered {
+		return enqueueBackordered(tx, order)
+	}
+	rk := os.Getenv("RABBITMQ_CONFIRM_ROUTING_KEY")
+	if rk == "" {
+		rk = "order.confirmed"
 	}
-	failedRoutingKey := os.Getenv("RABBITMQ_FAILED_ROUTING_KEY")
-	if failedRoutingKey == "" {
-		failedRoutingKey = "order.failed"
+	queue := os.Getenv("RABBITMQ_CONFIRM_QUEUE")
+	if queue == "" {
+		queue = "order_confirmed_queue"
+	}
+	if status == orderEntities.StatusCancelled {
+		rk = os.Getenv("RABBITMQ_FAILED_ROUTING_KEY")
+		if rk == "" {
+			rk = "order.failed"
+		}
+		queue = os.Getenv("RABBITMQ_FAILED_QUEUE")
+		if queue == "" {
+			queue = "order_failed_queue"
+		}
 	}
 
 	payload := events.OrderResultPayload{
-		OrderID: order.ID,
-		UserID:  order.UserID,
-		Status:  status,
-		Reason:  reason,
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Status:    string(status),
+		Reason:    reason,
 		CreatedAt: time.Now().UTC(),
 	}
-	body, _ := json.Marshal(payload)
-
-	_ = config.EnsureDirectExchange(ch, exchange)
-	_, _ = config.DeclareQuorumQueue(ch, os.Getenv("RABBITMQ_CONFIRM_QUEUE"), exchange, confirmedRoutingKey)
-	_, _ = config.DeclareQuorumQueue(ch, os.Getenv("RABBITMQ_FAILED_QUEUE"), exchange, failedRoutingKey)
+	return outboxRepo.Enqueue(tx, events.Exchange(), rk, queue, payload)
+}
 
-	rk := confirmedRoutingKey
-	if status == "CANCELLED" {
-		rk = failedRoutingKey
+func enqueueBackordered(tx *gorm.DB, order *orderEntities.Order) error {
+	queue := os.Getenv("RABBITMQ_BACKORDERED_QUEUE")
+	if queue == "" {
+		queue = "order_backordered_queue"
 	}
-
-	if err :=  config.PublishJSON(ch, exchange, rk, body); err != nil {
-		log.Printf("inventory: failed publish order result %s: %v", order.ID, err)
-		return
+	payload := events.OrderBackorderedPayload{
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, it := range order.Items {
+		if it.Status == orderEntities.ItemBackordered {
+			payload.Waiting = append(payload.Waiting, events.OrderItemPayload{
+				ProductID: it.ProductID,
+				VariantID: it.VariantID,
+				Quantity:  it.Quantity - it.FulfilledQuantity,
+			})
+		}
 	}
-	log.Printf("inventory: published order_%s for order %s", status, order.ID)
+	return outboxRepo.Enqueue(tx, events.Exchange(), events.OrderBackorderedRoutingKey(), queue, payload)
 }
@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// job is one delivery waiting for a pool goroutine. run reports whether
+// stock changed.
+type job struct {
+	d     amqp.Delivery
+	queue string
+	name  string
+	run   func() (bool, error)
+}
+
+// pool runs jobs on a fixed set of goroutines, one queue each. Jobs with the
+// same shard key always land on the same goroutine and run in the order they
+// were submitted.
+type pool struct {
+	shards []chan job
+	wg     sync.WaitGroup
+}
+
+func newPool(workers, buffer int, handle func(job)) *pool {
+	p := &pool{shards: make([]chan job, workers)}
+	perShard := max(buffer/workers, 1)
+	for i := range p.shards {
+		p.shards[i] = make(chan job, perShard)
+		p.wg.Add(1)
+		go func(jobs <-chan job) {
+			defer p.wg.Done()
+			for j := range jobs {
+				handle(j)
+			}
+		}(p.shards[i])
+	}
+	return p
+}
+
+// submit queues j on the goroutine for key, waiting while that goroutine is
+// busy. If ctx ends first the delivery is returned to its queue.
+func (p *pool) submit(ctx context.Context, key string, j job) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	select {
+	case p.shards[h.Sum32()%uint32(len(p.shards))] <- j:
+	case <-ctx.Done():
+		_ = j.d.Nack(false, true)
+	}
+}
+
+// drain stops accepting jobs and waits for the queued ones to finish.
+func (p *pool) drain() {
+	for _, s := range p.shards {
+		close(s)
+	}
+	p.wg.Wait()
+}
@@ -9,10 +9,12 @@ import (
 
 	"ecommerce-app/events"
 	"ecommerce-app/config"
+	productRepo "ecommerce-app/domain/products/repositories"
+	"ecommerce-app/shared/retry"
 
 )
 
-func StartNotificationWorker(ctx context.Context) error {
+func StartNotificationWorker(ctx context.Context, subscriptions productRepo.SubscriptionRepository) error {
 	ch, err := config.NewChannel()
 	if err != nil {
 		return err
@@ -38,12 +40,38 @@ func StartNotificationWorker(ctx context.Context) error {
 	if failedQueue == "" {
 		failedQueue = "order_failed_queue"
 	}
+	backInStockQueue := os.Getenv("RABBITMQ_BACK_IN_STOCK_QUEUE")
+	if backInStockQueue == "" {
+		backInStockQueue = "product_back_in_stock_queue"
+	}
+	lowStockQueue := os.Getenv("RABBITMQ_LOW_STOCK_QUEUE")
+	if lowStockQueue == "" {
+		lowStockQueue = "inventory_low_stock_queue"
+	}
+	cancelledQueue := os.Getenv("RABBITMQ_CANCELLED_QUEUE")
+	if cancelledQueue == "" {
+		cancelledQueue = "order_cancelled_queue"
+	}
+	backorderedQueue := os.Getenv("RABBITMQ_BACKORDERED_QUEUE")
+	if backorderedQueue == "" {
+		backorderedQueue = "order_backordered_queue"
+	}
 
 	if err := config.EnsureDirectExchange(ch, exchange); err != nil {
 		return err
 	}
-	_, _ = config.DeclareQuorumQueue(ch, confirmQueue, exchange, confirmRK)
-	_, _ = config.DeclareQuorumQueue(ch, failedQueue, exchange, failedRK)
+	for queue, rk := range map[string]string{
+		confirmQueue:     confirmRK,
+		failedQueue:      failedRK,
+		backInStockQueue: events.BackInStockRoutingKey(),
+		lowStockQueue:    events.LowStockRoutingKey(),
+		cancelledQueue:   events.OrderCancelledRoutingKey(),
+		backorderedQueue: events.OrderBackorderedRoutingKey(),
+	} {
+		if _, err := config.DeclareWorkQueue(ch, queue, exchange, rk); err != nil {
+			return err
+		}
+	}
 
 	confirmMsgs, err := ch.Consume(confirmQueue, "", false, false, false, false, nil)
 	if err != nil {
@@ -53,8 +81,24 @@ func StartNotificationWorker(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	backInStockMsgs, err := ch.Consume(backInStockQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	lowStockMsgs, err := ch.Consume(lowStockQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	cancelledMsgs, err := ch.Consume(cancelledQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	backorderedMsgs, err := ch.Consume(backorderedQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
 
-	log.Println("notification worker: consuming confirmed & failed queues")
+	log.Println("notification worker: consuming confirmed, failed, cancelled, backordered, back-in-stock & low-stock queues")
 
 	go func() {
 		for {
@@ -69,7 +113,7 @@ func StartNotificationWorker(ctx context.Context) error {
 				var payload events.OrderResultPayload
 				if err := json.Unmarshal(d.Body, &payload); err != nil {
 					log.Printf("notification: invalid confirmed payload: %v", err)
-					d.Nack(false, false)
+					config.Fail(d, exchange, confirmQueue, "invalid payload: "+err.Error(), false)
 					continue
 				}
 				log.Printf("notification: sending CONFIRM email for order %s to user %s", payload.OrderID, payload.UserID)
@@ -93,7 +137,7 @@ func StartNotificationWorker(ctx context.Context) error {
 				var payload events.OrderResultPayload
 				if err := json.Unmarshal(d.Body, &payload); err != nil {
 					log.Printf("notification: invalid failed payload: %v", err)
-					d.Nack(false, false)
+					config.Fail(d, exchange, failedQueue, "invalid payload: "+err.Error(), false)
 					continue
 				}
 				log.Printf("notification: sending CANCEL email for order %s to user %s (reason=%s)", payload.OrderID, payload.UserID, payload.Reason)
@@ -104,5 +148,125 @@ func StartNotificationWorker(ctx context.Context) error {
 		}
 	}()
 
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				_ = ch.Close()
+				return
+			case d, ok := <-cancelledMsgs:
+				if !ok {
+					return
+				}
+				var payload events.OrderCancelledPayload
+				if err := json.Unmarshal(d.Body, &payload); err != nil {
+					log.Printf("notification: invalid cancelled payload: %v", err)
+					config.Fail(d, exchange, cancelledQueue, "invalid payload: "+err.Error(), false)
+					continue
+				}
+				log.Printf("notification: sending CANCELLATION email for order %s to user %s (%d lines restocked)",
+					payload.OrderID, payload.UserID, len(payload.Restored))
+				time.Sleep(200 * time.Millisecond)
+				log.Printf("notification: CANCELLATION email sent for order %s", payload.OrderID)
+				d.Ack(false)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				_ = ch.Close()
+				return
+			case d, ok := <-backorderedMsgs:
+				if !ok {
+					return
+				}
+				var payload events.OrderBackorderedPayload
+				if err := json.Unmarshal(d.Body, &payload); err != nil {
+					log.Printf("notification: invalid backordered payload: %v", err)
+					config.Fail(d, exchange, backorderedQueue, "invalid payload: "+err.Error(), false)
+					continue
+				}
+				log.Printf("notification: sending BACKORDER email for order %s to user %s (%d lines waiting)",
+					payload.OrderID, payload.UserID, len(payload.Waiting))
+				time.Sleep(200 * time.Millisecond)
+				log.Printf("notification: BACKORDER email sent for order %s", payload.OrderID)
+				d.Ack(false)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				_ = ch.Close()
+				return
+			case d, ok := <-backInStockMsgs:
+				if !ok {
+					return
+				}
+				var payload events.BackInStockPayload
+				if err := json.Unmarshal(d.Body, &payload); err != nil {
+					log.Printf("notification: invalid back-in-stock payload: %v", err)
+					config.Fail(d, exchange, backInStockQueue, "invalid payload: "+err.Error(), false)
+					continue
+				}
+				if err := notifyBackInStock(subscriptions, payload); err != nil {
+					log.Printf("notification: back-in-stock for product %s failed: %v", payload.ProductID, err)
+					config.Fail(d, exchange, backInStockQueue, err.Error(), retry.Retryable(err))
+					continue
+				}
+				d.Ack(false)
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				_ = ch.Close()
+				return
+			case d, ok := <-lowStockMsgs:
+				if !ok {
+					return
+				}
+				var payload events.LowStockPayload
+				if err := json.Unmarshal(d.Body, &payload); err != nil {
+					log.Printf("notification: invalid low-stock payload: %v", err)
+					config.Fail(d, exchange, lowStockQueue, "invalid payload: "+err.Error(), false)
+					continue
+				}
+				log.Printf("notification: sending LOW-STOCK alert to staff for product %s %q (stock=%d threshold=%d)",
+					payload.ProductID, payload.Name, payload.Stock, payload.Threshold)
+				d.Ack(false)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// notifyBackInStock messages every pending subscriber of the item. Each
+// subscription is claimed before sending, so a redelivered event does not
+// message anyone twice.
+func notifyBackInStock(subscriptions productRepo.SubscriptionRepository, payload events.BackInStockPayload) error {
+	subs, err := subscriptions.FindPending(payload.ProductID, payload.VariantID)
+	if err != nil {
+		return err
+	}
+	for _, s := range subs {
+		claimed, err := subscriptions.MarkNotified(s.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+		log.Printf("notification: sending BACK-IN-STOCK email for product %s to user %s", payload.ProductID, s.UserID)
+	}
 	return nil
 }
@@ -0,0 +1,208 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"ecommerce-app/config"
+	outboxEntities "ecommerce-app/domain/outbox/entities"
+	outboxRepo "ecommerce-app/domain/outbox/repositories"
+	"ecommerce-app/shared/retry"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+)
+
+// claimLease is how long claimed messages are left to the relay that claimed
+// them. It must outlast publishing a whole batch.
+const claimLease = 2 * time.Minute
+
+// pruneInterval is how often sent messages past their retention are deleted.
+const pruneInterval = time.Minute
+
+// StartOutboxRelay publishes outbox messages every OUTBOX_POLL_MS (default
+// 1000), up to OUTBOX_BATCH_SIZE (default 100) at a time. Messages are claimed
+// in a short transaction and published outside it, each waiting for the
+// broker's confirm before it is marked sent; an unroutable message counts as
+// failed. Failures are retried with the OUTBOX_RETRY_* backoff until
+// OUTBOX_MAX_ATTEMPTS (default 20), after which the message is parked as
+// failed. Sent messages are deleted after OUTBOX_RETENTION_HOURS (default 24).
+// Delivery is at least once: a message confirmed just before a crash is sent
+// again. The returned channel is closed once the relay has stopped.
+func StartOutboxRelay(ctx context.Context, db *gorm.DB) <-chan struct{} {
+	interval := time.Second
+	if n, _ := strconv.Atoi(os.Getenv("OUTBOX_POLL_MS")); n > 0 {
+		interval = time.Duration(n) * time.Millisecond
+	}
+	batch := 100
+	if n, _ := strconv.Atoi(os.Getenv("OUTBOX_BATCH_SIZE")); n > 0 {
+		batch = n
+	}
+	maxAttempts := 20
+	if n, _ := strconv.Atoi(os.Getenv("OUTBOX_MAX_ATTEMPTS")); n > 0 {
+		maxAttempts = n
+	}
+	retention := 24 * time.Hour
+	if n, _ := strconv.Atoi(os.Getenv("OUTBOX_RETENTION_HOURS")); n > 0 {
+		retention = time.Duration(n) * time.Hour
+	}
+
+	log.Println("outbox relay: polling every", interval)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r := &relay{db: db, batch: batch, maxAttempts: maxAttempts, policy: retry.PolicyFromEnv("OUTBOX"), declared: map[string]bool{}}
+		defer r.closeChannel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var pruned time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("outbox relay: stopped")
+				return
+			case <-ticker.C:
+				// Keep going while full batches come back, so a backlog
+				// drains without waiting a tick per batch.
+				for {
+					n, err := r.relayBatch(ctx)
+					if err != nil {
+						log.Printf("outbox relay: %v", err)
+						break
+					}
+					if n < batch || ctx.Err() != nil {
+						break
+					}
+				}
+				if time.Since(pruned) >= pruneInterval {
+					pruned = time.Now()
+					if n, err := outboxRepo.DeleteSent(db, pruned.Add(-retention)); err != nil {
+						log.Printf("outbox relay: prune failed: %v", err)
+					} else if n > 0 {
+						log.Printf("outbox relay: deleted %d sent messages", n)
+					}
+				}
+			}
+		}
+	}()
+	return done
+}
+
+type relay struct {
+	db          *gorm.DB
+	batch       int
+	maxAttempts int
+	policy      retry.Policy
+	ch          *config.ConfirmChannel
+	declared    map[string]bool
+}
+
+// relayBatch publishes one batch of due messages and reports how many it
+// claimed. If the broker cannot be reached at all the batch is left for its
+// lease to run out rather than counted against each message.
+func (r *relay) relayBatch(ctx context.Context) (int, error) {
+	ch, err := r.channel()
+	if err != nil {
+		return 0, err
+	}
+
+	var msgs []outboxEntities.OutboxMessage
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		msgs, err = outboxRepo.ClaimDue(tx, time.Now(), r.batch, claimLease)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range msgs {
+		m := &msgs[i]
+		err := r.publish(ctx, ch, m)
+		if ctx.Err() != nil {
+			// Shutting down: whatever is left is picked up again once the
+			// lease runs out.
+			return len(msgs), nil
+		}
+		if err == nil {
+			if err := outboxRepo.MarkSent(r.db, m, time.Now()); err != nil {
+				return len(msgs), err
+			}
+			continue
+		}
+
+		var next *time.Time
+		if m.Attempts+1 < r.maxAttempts {
+			t := time.Now().Add(r.policy.Delay(m.Attempts))
+			next = &t
+			log.Printf("outbox relay: publish %s (%s) failed, attempt %d: %v", m.ID, m.RoutingKey, m.Attempts+1, err)
+		} else {
+			log.Printf("outbox relay: publish %s (%s) failed %d times, giving up: %v", m.ID, m.RoutingKey, m.Attempts+1, err)
+		}
+		if err := outboxRepo.MarkFailed(r.db, m, err, next); err != nil {
+			return len(msgs), err
+		}
+		if ch.IsClosed() {
+			// The rest of the batch waits for its lease rather than fail
+			// on a dead channel.
+			return len(msgs), nil
+		}
+	}
+	return len(msgs), nil
+}
+
+func (r *relay) publish(ctx context.Context, ch *config.ConfirmChannel, m *outboxEntities.OutboxMessage) error {
+	if !r.declared[m.Exchange] {
+		if err := config.EnsureDirectExchange(ch.Channel, m.Exchange); err != nil {
+			r.closeChannel()
+			return err
+		}
+		r.declared[m.Exchange] = true
+	}
+	if m.Queue != "" && !r.declared[m.Exchange+"/"+m.Queue] {
+		if _, err := config.DeclareWorkQueue(ch.Channel, m.Queue, m.Exchange, m.RoutingKey); err != nil {
+			r.closeChannel()
+			return err
+		}
+		r.declared[m.Exchange+"/"+m.Queue] = true
+	}
+
+	err := ch.PublishConfirmed(ctx, m.Exchange, m.RoutingKey, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    m.ID,
+		Timestamp:    m.CreatedAt,
+		Body:         m.Payload,
+	})
+	if err != nil && !errors.Is(err, config.ErrUnroutable) && !errors.Is(err, config.ErrNotConfirmed) {
+		r.closeChannel()
+	}
+	return err
+}
+
+// channel returns the relay's confirm-mode channel, opening a new one after
+// a failure closed the last.
+func (r *relay) channel() (*config.ConfirmChannel, error) {
+	if r.ch != nil && !r.ch.IsClosed() {
+		return r.ch, nil
+	}
+	ch, err := config.NewConfirmChannel()
+	if err != nil {
+		return nil, err
+	}
+	r.ch = ch
+	r.declared = map[string]bool{}
+	return ch, nil
+}
+
+func (r *relay) closeChannel() {
+	if r.ch != nil {
+		_ = r.ch.Close()
+		r.ch = nil
+	}
+}
@@ -0,0 +1,46 @@
+package reservation
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	inventoryRepo "ecommerce-app/domain/inventory/repositories"
+
+	"gorm.io/gorm"
+)
+
+// StartReservationSweeper periodically releases stock holds whose orders were
+// not confirmed before the hold expired.
+func StartReservationSweeper(ctx context.Context, db *gorm.DB) {
+	interval := 30 * time.Second
+	if v := os.Getenv("RESERVATION_SWEEP_SECONDS"); v != "" {
+		if parsed, _ := strconv.Atoi(v); parsed > 0 {
+			interval = time.Duration(parsed) * time.Second
+		}
+	}
+
+	log.Println("reservation sweeper: running every", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := inventoryRepo.ReleaseExpired(db, time.Now())
+				if err != nil {
+					log.Printf("reservation sweeper: release failed: %v", err)
+					continue
+				}
+				if n > 0 {
+					log.Printf("reservation sweeper: released %d expired holds", n)
+				}
+			}
+		}
+	}()
+}